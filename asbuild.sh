@@ -1 +1 @@
-GOARCH=wasm GOOS=js go build -o assembly/engine.wasm assembly/engine/*.go
+GOARCH=wasm GOOS=js go build -o assembly/engine.wasm ./assembly/engine
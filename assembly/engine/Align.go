@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// AlignOperation 对齐
+type AlignOperation int
+
+const (
+	// ALIGNLEFT 左对齐
+	ALIGNLEFT AlignOperation = iota
+	// ALIGNRIGHT 右对齐
+	ALIGNRIGHT
+	// ALIGNTOP 顶对齐
+	ALIGNTOP
+	// ALIGNBOTTOM 底对齐
+	ALIGNBOTTOM
+	// ALIGNHCENTER 水平居中 中心的x相同
+	ALIGNHCENTER
+	// ALIGNVCENTER 垂直居中 中心的y相同
+	ALIGNVCENTER
+)
+
+// DistributeOperation 分布
+type DistributeOperation int
+
+const (
+	// DISTRIBUTEHGAP 水平等间距 相邻控件之间的空隙相同
+	DISTRIBUTEHGAP DistributeOperation = iota
+	// DISTRIBUTEVGAP 垂直等间距
+	DISTRIBUTEVGAP
+	// DISTRIBUTEHCENTER 水平中心等距 相邻控件中心的x距离相同
+	DISTRIBUTEHCENTER
+	// DISTRIBUTEVCENTER 垂直中心等距
+	DISTRIBUTEVCENTER
+)
+
+// 对齐时每个矩形的位移 reference 为对齐的参照范围
+func alignOffsets(rects []snapRect, reference snapRect, op AlignOperation) []Point {
+	offsets := make([]Point, len(rects))
+	for idx, r := range rects {
+		switch op {
+		case ALIGNLEFT:
+			offsets[idx].x = reference.minX - r.minX
+		case ALIGNRIGHT:
+			offsets[idx].x = reference.maxX - r.maxX
+		case ALIGNTOP:
+			offsets[idx].y = reference.minY - r.minY
+		case ALIGNBOTTOM:
+			offsets[idx].y = reference.maxY - r.maxY
+		case ALIGNHCENTER:
+			offsets[idx].x = (reference.minX+reference.maxX)/2 - (r.minX+r.maxX)/2
+		case ALIGNVCENTER:
+			offsets[idx].y = (reference.minY+reference.maxY)/2 - (r.minY+r.maxY)/2
+		}
+	}
+	return offsets
+}
+
+// 分布时每个矩形的位移 按中心排序后依次排列 少于3个时不移动
+// 等间距时所有矩形的总范围不变 中心等距时两端矩形的中心不动
+func distributeOffsets(rects []snapRect, op DistributeOperation) []Point {
+	offsets := make([]Point, len(rects))
+	if len(rects) < 3 {
+		return offsets
+	}
+	horizontal := op == DISTRIBUTEHGAP || op == DISTRIBUTEHCENTER
+	// 矩形在分布方向上的起点和终点
+	span := func(r snapRect) (float64, float64) {
+		if horizontal {
+			return r.minX, r.maxX
+		}
+		return r.minY, r.maxY
+	}
+	order := make([]int, len(rects))
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a1, a2 := span(rects[order[i]])
+		b1, b2 := span(rects[order[j]])
+		return a1+a2 < b1+b2
+	})
+
+	n := len(order)
+	targets := make([]float64, n) //每个矩形的新起点
+	if op == DISTRIBUTEHGAP || op == DISTRIBUTEVGAP {
+		//大的矩形可能越过两端矩形 总范围取所有矩形的最小起点和最大终点
+		start, end := math.Inf(1), math.Inf(-1)
+		total := 0.0
+		for _, idx := range order {
+			s1, s2 := span(rects[idx])
+			start = math.Min(start, s1)
+			end = math.Max(end, s2)
+			total += s2 - s1
+		}
+		gap := (end - start - total) / float64(n-1)
+		pos := start
+		for k, idx := range order {
+			s1, s2 := span(rects[idx])
+			targets[k] = pos
+			pos += s2 - s1 + gap
+		}
+	} else {
+		first1, first2 := span(rects[order[0]])
+		last1, last2 := span(rects[order[n-1]])
+		c0 := (first1 + first2) / 2
+		step := ((last1+last2)/2 - c0) / float64(n-1)
+		for k, idx := range order {
+			s1, s2 := span(rects[idx])
+			targets[k] = c0 + step*float64(k) - (s2-s1)/2
+		}
+	}
+	for k, idx := range order {
+		s1, _ := span(rects[idx])
+		if horizontal {
+			offsets[idx].x = targets[k] - s1
+		} else {
+			offsets[idx].y = targets[k] - s1
+		}
+	}
+	return offsets
+}
@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+// 水平方向的矩形 高度固定为10
+func hrect(x1, x2 float64) snapRect {
+	return snapRect{x1, 0, x2, 10}
+}
+
+// 垂直方向的矩形 宽度固定为10
+func vrect(y1, y2 float64) snapRect {
+	return snapRect{0, y1, 10, y2}
+}
+
+func equalOffsets(got, want []Point) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for idx := range got {
+		if got[idx] != want[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAlignOffsets(t *testing.T) {
+	reference := snapRect{0, 0, 100, 50}
+	rects := []snapRect{{10, 20, 30, 40}, {0, 0, 100, 50}}
+	cases := []struct {
+		name string
+		op   AlignOperation
+		want []Point
+	}{
+		{"左对齐", ALIGNLEFT, []Point{{-10, 0}, {0, 0}}},
+		{"右对齐", ALIGNRIGHT, []Point{{70, 0}, {0, 0}}},
+		{"顶对齐", ALIGNTOP, []Point{{0, -20}, {0, 0}}},
+		{"底对齐", ALIGNBOTTOM, []Point{{0, 10}, {0, 0}}},
+		{"水平居中", ALIGNHCENTER, []Point{{30, 0}, {0, 0}}},
+		{"垂直居中", ALIGNVCENTER, []Point{{0, -5}, {0, 0}}},
+	}
+	for _, c := range cases {
+		if got := alignOffsets(rects, reference, c.op); !equalOffsets(got, c.want) {
+			t.Errorf("%s: alignOffsets = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDistributeOffsets(t *testing.T) {
+	cases := []struct {
+		name  string
+		rects []snapRect
+		op    DistributeOperation
+		want  []Point
+	}{
+		{"少于3个", []snapRect{hrect(0, 10), hrect(50, 60)}, DISTRIBUTEHGAP, []Point{{0, 0}, {0, 0}}},
+		{"水平等间距", []snapRect{hrect(0, 10), hrect(12, 22), hrect(40, 50)}, DISTRIBUTEHGAP,
+			[]Point{{0, 0}, {8, 0}, {0, 0}}},
+		{"水平等间距 乱序", []snapRect{hrect(40, 50), hrect(0, 10), hrect(12, 22)}, DISTRIBUTEHGAP,
+			[]Point{{0, 0}, {0, 0}, {8, 0}}},
+		{"垂直等间距 宽度不同", []snapRect{vrect(0, 20), vrect(30, 35), vrect(70, 100)}, DISTRIBUTEVGAP,
+			[]Point{{0, 0}, {0, 12.5}, {0, 0}}},
+		{"等间距 中间的矩形越过末端", []snapRect{hrect(0, 10), hrect(10, 90), hrect(60, 70)}, DISTRIBUTEHGAP,
+			[]Point{{0, 0}, {-5, 0}, {20, 0}}},
+		{"等间距 中间的矩形越过首端", []snapRect{hrect(30, 40), hrect(0, 80), hrect(90, 100)}, DISTRIBUTEHGAP,
+			[]Point{{-30, 0}, {10, 0}, {0, 0}}},
+		{"水平中心等距", []snapRect{hrect(0, 10), hrect(10, 20), hrect(90, 100)}, DISTRIBUTEHCENTER,
+			[]Point{{0, 0}, {35, 0}, {0, 0}}},
+		{"垂直中心等距 宽度不同", []snapRect{vrect(0, 10), vrect(20, 30), vrect(95, 105)}, DISTRIBUTEVCENTER,
+			[]Point{{0, 0}, {0, 27.5}, {0, 0}}},
+	}
+	for _, c := range cases {
+		if got := distributeOffsets(c.rects, c.op); !equalOffsets(got, c.want) {
+			t.Errorf("%s: distributeOffsets = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
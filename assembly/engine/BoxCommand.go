@@ -0,0 +1,534 @@
+package main
+
+import "math"
+
+// boxGeometry 控件的几何信息 坐标相对父节点
+type boxGeometry struct {
+	x      int
+	y      int
+	width  int
+	height int
+	angle  float64
+}
+
+// 获取控件的几何信息
+func getBoxGeometry(box *Box) boxGeometry {
+	return boxGeometry{box.x, box.y, box.width, box.height, box.angle}
+}
+
+/////////////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////  CreateBoxCommand start /////////////////////////////////
+/////////////////////////////////////////////////////////////////////////////////////////
+
+// CreateBoxCommand 创建控件
+type CreateBoxCommand struct {
+	engine *Engine
+	box    *Box
+	parent *Box
+	index  int
+}
+
+// NewCreateBoxCommand 构造函数 index为-1时放到最上层
+func NewCreateBoxCommand(engine *Engine, box *Box, parent *Box, index int) *CreateBoxCommand {
+	return &CreateBoxCommand{engine, box, parent, index}
+}
+
+// Do 执行
+func (t *CreateBoxCommand) Do() {
+	t.engine.attachBox(t.box, t.parent, t.index)
+}
+
+// Undo 撤销
+func (t *CreateBoxCommand) Undo() {
+	t.engine.removeBox(t.box)
+}
+
+// Merge 不合并
+func (t *CreateBoxCommand) Merge(next Command) bool {
+	return false
+}
+
+/////////////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////  DeleteBoxCommand start /////////////////////////////////
+/////////////////////////////////////////////////////////////////////////////////////////
+
+// DeleteBoxCommand 删除控件（连同子控件）
+type DeleteBoxCommand struct {
+	engine *Engine
+	box    *Box
+	parent *Box
+	index  int
+}
+
+// NewDeleteBoxCommand 构造函数
+func NewDeleteBoxCommand(engine *Engine, box *Box) *DeleteBoxCommand {
+	return &DeleteBoxCommand{engine, box, box.parent, engine.boxTree.IndexOfChild(box)}
+}
+
+// Do 执行
+func (t *DeleteBoxCommand) Do() {
+	t.engine.removeBox(t.box)
+}
+
+// Undo 撤销
+func (t *DeleteBoxCommand) Undo() {
+	t.engine.attachBox(t.box, t.parent, t.index)
+}
+
+// Merge 不合并
+func (t *DeleteBoxCommand) Merge(next Command) bool {
+	return false
+}
+
+/////////////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////  GeometryCommand start //////////////////////////////////
+/////////////////////////////////////////////////////////////////////////////////////////
+
+// boxGeometryCommand 修改控件几何信息的基类
+type boxGeometryCommand struct {
+	engine *Engine
+	box    *Box
+	from   boxGeometry
+	to     boxGeometry
+}
+
+// Do 执行
+func (t *boxGeometryCommand) Do() {
+	t.engine.setBoxGeometry(t.box, t.to)
+}
+
+// Undo 撤销
+func (t *boxGeometryCommand) Undo() {
+	t.engine.setBoxGeometry(t.box, t.from)
+}
+
+// MoveBoxCommand 平移控件
+type MoveBoxCommand struct {
+	boxGeometryCommand
+}
+
+// NewMoveBoxCommand 构造函数 x y 相对父节点
+func NewMoveBoxCommand(engine *Engine, box *Box, x, y int) *MoveBoxCommand {
+	from := getBoxGeometry(box)
+	to := from
+	to.x = x
+	to.y = y
+	return &MoveBoxCommand{boxGeometryCommand{engine, box, from, to}}
+}
+
+// Merge 合并同一控件的连续平移
+func (t *MoveBoxCommand) Merge(next Command) bool {
+	if !t.CanMerge(next) {
+		return false
+	}
+	t.to = next.(*MoveBoxCommand).to
+	return true
+}
+
+// CanMerge 同一控件的平移
+func (t *MoveBoxCommand) CanMerge(next Command) bool {
+	cmd, ok := next.(*MoveBoxCommand)
+	return ok && cmd.box == t.box
+}
+
+// ResizeBoxCommand 拉伸控件 拉伸可能同时改变位置 拉伸组合时成员按比例缩放
+type ResizeBoxCommand struct {
+	boxGeometryCommand
+	members map[*Box]boxGeometry //组合拉伸前所有后代的几何信息 不是组合时为nil
+}
+
+// NewResizeBoxCommand 构造函数 x y 相对父节点
+func NewResizeBoxCommand(engine *Engine, box *Box, x, y, width, height int) *ResizeBoxCommand {
+	from := getBoxGeometry(box)
+	to := boxGeometry{x, y, width, height, box.angle}
+	cmd := &ResizeBoxCommand{boxGeometryCommand{engine, box, from, to}, nil}
+	if box.IsGroup() {
+		cmd.members = make(map[*Box]boxGeometry)
+		for _, b := range getDescendants(box) {
+			cmd.members[b] = getBoxGeometry(b)
+		}
+	}
+	return cmd
+}
+
+// Do 执行
+func (t *ResizeBoxCommand) Do() {
+	if t.members == nil {
+		t.boxGeometryCommand.Do()
+		return
+	}
+	geometries := map[*Box]boxGeometry{t.box: t.to}
+	scaleGroupMembers(t.box, t.from, t.to, t.members, geometries)
+	t.engine.setBoxesGeometry(t.box, geometries)
+}
+
+// Undo 撤销
+func (t *ResizeBoxCommand) Undo() {
+	if t.members == nil {
+		t.boxGeometryCommand.Undo()
+		return
+	}
+	geometries := map[*Box]boxGeometry{t.box: t.from}
+	for b, g := range t.members {
+		geometries[b] = g
+	}
+	t.engine.setBoxesGeometry(t.box, geometries)
+}
+
+// Merge 合并同一控件的连续拉伸
+// 组合的成员按拉伸开始时的几何信息重新缩放 避免每一步取整累积误差 使拖拽中的结果与重做一致
+func (t *ResizeBoxCommand) Merge(next Command) bool {
+	if !t.CanMerge(next) {
+		return false
+	}
+	t.to = next.(*ResizeBoxCommand).to
+	if t.members != nil {
+		t.Do()
+	}
+	return true
+}
+
+// CanMerge 同一控件的拉伸
+func (t *ResizeBoxCommand) CanMerge(next Command) bool {
+	cmd, ok := next.(*ResizeBoxCommand)
+	return ok && cmd.box == t.box
+}
+
+// 组合从 from 拉伸到 to 时按比例计算成员的几何信息 成员的中心和尺寸随组合缩放 角度不变
+// 接近竖直的成员交换横纵比例 嵌套的组合按自己的比例继续缩放 origins 为拉伸前的几何信息
+func scaleGroupMembers(group *Box, from, to boxGeometry, origins map[*Box]boxGeometry, geometries map[*Box]boxGeometry) {
+	sx := float64(to.width) / float64(intMax(from.width, 1))
+	sy := float64(to.height) / float64(intMax(from.height, 1))
+	for _, child := range group.children {
+		origin, ok := origins[child]
+		if !ok {
+			continue
+		}
+		ax, ay := sx, sy
+		if math.Abs(math.Sin(origin.angle)) > math.Sqrt2/2 {
+			ax, ay = sy, sx
+		}
+		cx := (float64(origin.x) + float64(origin.width)/2) * sx
+		cy := (float64(origin.y) + float64(origin.height)/2) * sy
+		geometry := origin
+		geometry.width = intMax(round(float64(origin.width)*ax), 1)
+		geometry.height = intMax(round(float64(origin.height)*ay), 1)
+		geometry.x = round(cx - float64(geometry.width)/2)
+		geometry.y = round(cy - float64(geometry.height)/2)
+		geometries[child] = geometry
+		if child.IsGroup() {
+			scaleGroupMembers(child, origin, geometry, origins, geometries)
+		}
+	}
+}
+
+// RotateBoxCommand 旋转控件
+type RotateBoxCommand struct {
+	boxGeometryCommand
+}
+
+// NewRotateBoxCommand 构造函数
+func NewRotateBoxCommand(engine *Engine, box *Box, angle float64) *RotateBoxCommand {
+	from := getBoxGeometry(box)
+	to := from
+	to.angle = angle
+	return &RotateBoxCommand{boxGeometryCommand{engine, box, from, to}}
+}
+
+// Merge 合并同一控件的连续旋转
+func (t *RotateBoxCommand) Merge(next Command) bool {
+	if !t.CanMerge(next) {
+		return false
+	}
+	t.to = next.(*RotateBoxCommand).to
+	return true
+}
+
+// CanMerge 同一控件的旋转
+func (t *RotateBoxCommand) CanMerge(next Command) bool {
+	cmd, ok := next.(*RotateBoxCommand)
+	return ok && cmd.box == t.box
+}
+
+/////////////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////  StyleBoxCommand start //////////////////////////////////
+/////////////////////////////////////////////////////////////////////////////////////////
+
+// StyleBoxCommand 修改控件样式
+type StyleBoxCommand struct {
+	engine *Engine
+	box    *Box
+	from   string
+	to     string
+}
+
+// NewStyleBoxCommand 构造函数
+func NewStyleBoxCommand(engine *Engine, box *Box, styleClass string) *StyleBoxCommand {
+	return &StyleBoxCommand{engine, box, box.styleClass, styleClass}
+}
+
+// Do 执行
+func (t *StyleBoxCommand) Do() {
+	t.box.styleClass = t.to
+	t.engine.styleSheet.InvalidateStyle(t.box)
+	t.engine.render.PaintBox(t.box)
+}
+
+// Undo 撤销
+func (t *StyleBoxCommand) Undo() {
+	t.box.styleClass = t.from
+	t.engine.styleSheet.InvalidateStyle(t.box)
+	t.engine.render.PaintBox(t.box)
+}
+
+// Merge 不合并
+func (t *StyleBoxCommand) Merge(next Command) bool {
+	return false
+}
+
+// InlineStyleBoxCommand 修改控件的内联样式
+type InlineStyleBoxCommand struct {
+	engine *Engine
+	box    *Box
+	from   *StyleRule
+	to     *StyleRule
+}
+
+// NewInlineStyleBoxCommand 构造函数 rule为nil时清除内联样式
+func NewInlineStyleBoxCommand(engine *Engine, box *Box, rule *StyleRule) *InlineStyleBoxCommand {
+	return &InlineStyleBoxCommand{engine, box, box.inlineStyle, rule}
+}
+
+// Do 执行
+func (t *InlineStyleBoxCommand) Do() {
+	t.box.inlineStyle = t.to
+	t.engine.styleSheet.InvalidateStyle(t.box)
+	t.engine.render.PaintBox(t.box)
+}
+
+// Undo 撤销
+func (t *InlineStyleBoxCommand) Undo() {
+	t.box.inlineStyle = t.from
+	t.engine.styleSheet.InvalidateStyle(t.box)
+	t.engine.render.PaintBox(t.box)
+}
+
+// Merge 不合并
+func (t *InlineStyleBoxCommand) Merge(next Command) bool {
+	return false
+}
+
+// RenameBoxCommand 修改控件名称
+type RenameBoxCommand struct {
+	box  *Box
+	from string
+	to   string
+}
+
+// NewRenameBoxCommand 构造函数
+func NewRenameBoxCommand(box *Box, name string) *RenameBoxCommand {
+	return &RenameBoxCommand{box, box.name, name}
+}
+
+// Do 执行
+func (t *RenameBoxCommand) Do() {
+	t.box.name = t.to
+}
+
+// Undo 撤销
+func (t *RenameBoxCommand) Undo() {
+	t.box.name = t.from
+}
+
+// Merge 不合并
+func (t *RenameBoxCommand) Merge(next Command) bool {
+	return false
+}
+
+/////////////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////  ReparentBoxCommand start ///////////////////////////////
+/////////////////////////////////////////////////////////////////////////////////////////
+
+// ReparentBoxCommand 修改控件的容器 同时修改相对坐标和角度
+// 原来的层级在执行时记录 批量操作中前面的操作已经改变了兄弟节点
+type ReparentBoxCommand struct {
+	engine     *Engine
+	box        *Box
+	fromParent *Box
+	fromIndex  int
+	from       boxGeometry
+	toParent   *Box
+	toIndex    int
+	to         boxGeometry
+}
+
+// NewReparentBoxCommand 构造函数 geometry 为box在新容器中的几何信息 index为-1时放到最上层
+func NewReparentBoxCommand(engine *Engine, box *Box, parent *Box, index int, geometry boxGeometry) *ReparentBoxCommand {
+	return &ReparentBoxCommand{engine, box, box.parent, -1, getBoxGeometry(box), parent, index, geometry}
+}
+
+// Do 执行
+func (t *ReparentBoxCommand) Do() {
+	t.fromIndex = t.engine.boxTree.IndexOfChild(t.box)
+	t.engine.setBoxParent(t.box, t.toParent, t.toIndex, t.to)
+}
+
+// Undo 撤销
+func (t *ReparentBoxCommand) Undo() {
+	t.engine.setBoxParent(t.box, t.fromParent, t.fromIndex, t.from)
+}
+
+// Merge 不合并
+func (t *ReparentBoxCommand) Merge(next Command) bool {
+	return false
+}
+
+/////////////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////  GroupBoxesCommand start ////////////////////////////////
+/////////////////////////////////////////////////////////////////////////////////////////
+
+// GroupBoxesCommand 把一组兄弟控件放入组合 撤销时恢复成员原来的层级和几何信息
+type GroupBoxesCommand struct {
+	engine   *Engine
+	group    *Box
+	boxes    []*Box
+	parent   *Box
+	children []*Box        //组合前父节点的children
+	from     []boxGeometry //组合前成员的几何信息
+}
+
+// NewGroupBoxesCommand 构造函数 boxes 必须在同一个容器中 group 为新的空组合
+func NewGroupBoxesCommand(engine *Engine, boxes []*Box, group *Box) *GroupBoxesCommand {
+	return &GroupBoxesCommand{engine, group, append([]*Box{}, boxes...), boxes[0].parent, nil, nil}
+}
+
+// Do 执行
+func (t *GroupBoxesCommand) Do() {
+	t.children = append([]*Box{}, t.parent.children...)
+	t.from = make([]boxGeometry, len(t.boxes))
+	for idx, box := range t.boxes {
+		t.from[idx] = getBoxGeometry(box)
+	}
+	t.engine.groupBoxes(t.boxes, t.group)
+}
+
+// Undo 撤销
+func (t *GroupBoxesCommand) Undo() {
+	t.engine.ungroupBox(t.group)
+	for idx, box := range t.boxes {
+		t.engine.setBoxGeometry(box, t.from[idx])
+	}
+	t.engine.boxTree.SetChildren(t.parent, t.children)
+	t.engine.paintBoxes(t.boxes)
+}
+
+// Merge 不合并
+func (t *GroupBoxesCommand) Merge(next Command) bool {
+	return false
+}
+
+// UngroupBoxCommand 解散组合 原来的层级和几何信息在执行时记录
+type UngroupBoxCommand struct {
+	engine   *Engine
+	group    *Box
+	parent   *Box
+	index    int
+	geometry boxGeometry
+	members  []*Box
+	from     []boxGeometry //成员在组合中的几何信息
+}
+
+// NewUngroupBoxCommand 构造函数
+func NewUngroupBoxCommand(engine *Engine, group *Box) *UngroupBoxCommand {
+	return &UngroupBoxCommand{engine, group, nil, -1, boxGeometry{}, nil, nil}
+}
+
+// Do 执行
+func (t *UngroupBoxCommand) Do() {
+	t.parent = t.group.parent
+	t.index = t.engine.boxTree.IndexOfChild(t.group)
+	t.geometry = getBoxGeometry(t.group)
+	t.members = append([]*Box{}, t.group.children...)
+	t.from = make([]boxGeometry, len(t.members))
+	for idx, box := range t.members {
+		t.from[idx] = getBoxGeometry(box)
+	}
+	t.engine.ungroupBox(t.group)
+}
+
+// Undo 撤销
+func (t *UngroupBoxCommand) Undo() {
+	t.engine.restoreGroup(t.group, t.parent, t.index, t.geometry, t.members, t.from)
+}
+
+// Merge 不合并
+func (t *UngroupBoxCommand) Merge(next Command) bool {
+	return false
+}
+
+/////////////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////  ReorderBoxCommand start ////////////////////////////////
+/////////////////////////////////////////////////////////////////////////////////////////
+
+// ReorderBoxCommand 调整控件在兄弟节点中的层级
+type ReorderBoxCommand struct {
+	engine *Engine
+	box    *Box
+	from   int
+	to     int
+}
+
+// NewReorderBoxCommand 构造函数
+func NewReorderBoxCommand(engine *Engine, box *Box, index int) *ReorderBoxCommand {
+	return &ReorderBoxCommand{engine, box, engine.boxTree.IndexOfChild(box), index}
+}
+
+// Do 执行
+func (t *ReorderBoxCommand) Do() {
+	t.engine.boxTree.SetZIndex(t.box, t.to)
+	t.engine.paintBoxes([]*Box{t.box})
+}
+
+// Undo 撤销
+func (t *ReorderBoxCommand) Undo() {
+	t.engine.boxTree.SetZIndex(t.box, t.from)
+	t.engine.paintBoxes([]*Box{t.box})
+}
+
+// Merge 不合并
+func (t *ReorderBoxCommand) Merge(next Command) bool {
+	return false
+}
+
+// ReorderChildrenCommand 重新排列容器的children 用于同时调整多个兄弟控件的层级
+type ReorderChildrenCommand struct {
+	engine *Engine
+	parent *Box
+	from   []*Box
+	to     []*Box
+	boxes  []*Box //层级变化的控件 重绘它们所在的区域
+}
+
+// NewReorderChildrenCommand 构造函数 children 为新的顺序
+func NewReorderChildrenCommand(engine *Engine, parent *Box, children []*Box, boxes []*Box) *ReorderChildrenCommand {
+	from := make([]*Box, len(parent.children))
+	copy(from, parent.children)
+	return &ReorderChildrenCommand{engine, parent, from, children, boxes}
+}
+
+// Do 执行
+func (t *ReorderChildrenCommand) Do() {
+	t.engine.boxTree.SetChildren(t.parent, t.to)
+	t.engine.paintBoxes(t.boxes)
+}
+
+// Undo 撤销
+func (t *ReorderChildrenCommand) Undo() {
+	t.engine.boxTree.SetChildren(t.parent, t.from)
+	t.engine.paintBoxes(t.boxes)
+}
+
+// Merge 不合并
+func (t *ReorderChildrenCommand) Merge(next Command) bool {
+	return false
+}
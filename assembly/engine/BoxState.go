@@ -1,6 +1,9 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+)
 
 // BoxStateInterface 交互状态对象
 type BoxStateInterface interface {
@@ -117,6 +120,10 @@ func (t *BoxNormalState) Start() {
 			t.eventsHandler(IN)
 		}
 	})
+	// 从选中状态退回时 鼠标可能仍在box上 不会再收到MOUSEENTER
+	if t.engine.mouseEvent.IsHovering(t.target) && t.eventsHandler != nil {
+		t.eventsHandler(IN)
+	}
 }
 
 /////////////////////////////////////////////////////////////////////////////////////////
@@ -128,6 +135,7 @@ type BoxHoverState struct {
 	BoxBasicState
 	leaveListener     *EventListener
 	dragstartListener *EventListener
+	clickListener     *EventListener
 }
 
 // NewBoxHoverState 构造函数
@@ -141,16 +149,21 @@ func NewBoxHoverState(target *Box, engine *Engine) (state *BoxHoverState) {
 // Stop 停止状态
 func (t *BoxHoverState) Stop() {
 	t.isRunning = false
+	t.target.isHovering = false
+	t.engine.styleSheet.InvalidateStyle(t.target)
 	for _, view := range t.views {
 		view.Close()
 	}
 	t.removeEventListener(t.target, t.dragstartListener)
 	t.removeEventListener(t.target, t.leaveListener)
+	t.removeEventListener(t.target, t.clickListener)
 }
 
 // Start 开始状态
 func (t *BoxHoverState) Start() {
 	t.isRunning = true
+	t.target.isHovering = true
+	t.engine.styleSheet.InvalidateStyle(t.target)
 	for _, view := range t.views {
 		view.Render()
 	}
@@ -164,17 +177,339 @@ func (t *BoxHoverState) Start() {
 			t.eventsHandler(OUT)
 		}
 	})
+	// 选中由引擎统一管理 引擎会通知状态机跳转到SELECTED 按住Shift或Ctrl点击时加入多选
+	t.clickListener = t.addEventListener(t.target, CLICK, func(evt MouseEvent) {
+		if evt.IsMultiSelect() {
+			t.engine.ToggleSelection(t.target)
+		} else {
+			t.engine.SelectBox(t.target)
+		}
+	})
+}
+
+/////////////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////  BoxSelectedState start /////////////////////////////////
+/////////////////////////////////////////////////////////////////////////////////////////
+
+// BoxSelectedState 选中状态 单选时显示边框和控制点 多选时只显示边框
+type BoxSelectedState struct {
+	BoxBasicState
+	handles                 *BoxResizeHandlesView
+	rotateHandle            *BoxRotateHandleView
+	editable                bool //是否显示控制点
+	dragstartListener       *EventListener
+	clickListener           *EventListener
+	dbclickListener         *EventListener
+	handleDragstartListener []*EventListener
+	rotateStartListener     *EventListener
+}
+
+// NewBoxSelectedState 构造函数
+func NewBoxSelectedState(target *Box, handles *BoxResizeHandlesView, rotateHandle *BoxRotateHandleView, engine *Engine) (state *BoxSelectedState) {
+	state = &BoxSelectedState{}
+	state.initState(target, engine)
+	state.handles = handles
+	state.rotateHandle = rotateHandle
+	state.handleDragstartListener = make([]*EventListener, 0, len(handles.Handles()))
+	state.views = append(state.views, NewBoxBorderView(target, "selectedborder", engine), handles, rotateHandle)
+	return state
+}
+
+// Views 获取正在显示的视图列表
+func (t *BoxSelectedState) Views() []BoxStateViewInterface {
+	if t.editable {
+		return t.views
+	}
+	return t.views[:1]
+}
+
+// Stop 停止状态
+func (t *BoxSelectedState) Stop() {
+	t.isRunning = false
+	for _, view := range t.Views() {
+		view.Close()
+	}
+	t.removeEventListener(t.target, t.dragstartListener)
+	t.removeEventListener(t.target, t.clickListener)
+	if t.dbclickListener != nil {
+		t.removeEventListener(t.target, t.dbclickListener)
+		t.dbclickListener = nil
+	}
+	if !t.editable {
+		return
+	}
+	for idx, handle := range t.handles.Handles() {
+		t.removeEventListener(handle.Box(), t.handleDragstartListener[idx])
+	}
+	t.handleDragstartListener = t.handleDragstartListener[:0]
+	t.removeEventListener(t.rotateHandle.Handle(), t.rotateStartListener)
+}
+
+// Start 开始状态
+func (t *BoxSelectedState) Start() {
+	t.isRunning = true
+	t.editable = !t.engine.IsMultiSelection()
+	for _, view := range t.Views() {
+		view.Render()
+	}
+	t.dragstartListener = t.addEventListener(t.target, DRAGSTART, func(evt MouseEvent) {
+		if t.eventsHandler != nil {
+			t.eventsHandler(MOVESTART)
+		}
+	})
+	// 按住Shift或Ctrl点击时取消选中 否则只选中这一个
+	t.clickListener = t.addEventListener(t.target, CLICK, func(evt MouseEvent) {
+		if evt.IsMultiSelect() {
+			t.engine.ToggleSelection(t.target)
+		} else {
+			t.engine.SelectBox(t.target)
+		}
+	})
+	// 双击组合 进入组合并选中鼠标下的成员
+	if t.target.IsGroup() {
+		t.dbclickListener = t.addEventListener(t.target, DBCLICK, func(evt MouseEvent) {
+			t.engine.EnterGroup(t.target)
+			if box := t.engine.mouseEvent.GetBoxAt(evt.mouseX, evt.mouseY); isAncestor(t.target, box) {
+				t.engine.SelectBox(box)
+			} else {
+				t.engine.SelectBox(nil)
+			}
+		})
+	}
+	if !t.editable {
+		return
+	}
+	// 拖拽控制点 进入对应的拉伸状态
+	for _, handle := range t.handles.Handles() {
+		event := stretchStartEvents[handle.State()]
+		listener := t.addEventListener(handle.Box(), DRAGSTART, func(evt MouseEvent) {
+			if t.eventsHandler != nil {
+				t.eventsHandler(event)
+			}
+		})
+		t.handleDragstartListener = append(t.handleDragstartListener, listener)
+	}
+	// 拖拽旋转控制点 进入旋转状态
+	t.rotateStartListener = t.addEventListener(t.rotateHandle.Handle(), DRAGSTART, func(evt MouseEvent) {
+		if t.eventsHandler != nil {
+			t.eventsHandler(ROTATESTART)
+		}
+	})
+}
+
+/////////////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////  BoxStretchState start //////////////////////////////////
+/////////////////////////////////////////////////////////////////////////////////////////
+
+// 拉伸状态对应的开始和结束行为
+var stretchStartEvents = map[BoxState]BoxEvent{STRETCH: STRETCHSTART, HSTRETCH: HSTRETCHSTART, VSTRETCH: VSTRETCHSTART}
+var stretchEndEvents = map[BoxState]BoxEvent{STRETCH: STRETCHEND, HSTRETCH: HSTRETCHEND, VSTRETCH: VSTRETCHEND}
+
+// BoxStretchState 拉伸状态 mode 为 STRETCH HSTRETCH VSTRETCH 之一
+type BoxStretchState struct {
+	BoxBasicState
+	mode      BoxState
+	handles   *BoxResizeHandlesView
+	listeners []*EventListener
+	// 拉伸开始时的几何信息 绝对坐标
+	cx, cy        float64
+	width, height float64
+}
+
+// NewBoxStretchState 构造函数
+func NewBoxStretchState(target *Box, mode BoxState, handles *BoxResizeHandlesView, rotateHandle *BoxRotateHandleView, engine *Engine) (state *BoxStretchState) {
+	state = &BoxStretchState{}
+	state.initState(target, engine)
+	state.mode = mode
+	state.handles = handles
+	state.listeners = make([]*EventListener, 0)
+	state.views = append(state.views, NewBoxBorderView(target, "selectedborder", engine), handles, rotateHandle)
+	return state
+}
+
+// Stop 停止状态
+func (t *BoxStretchState) Stop() {
+	t.isRunning = false
+	for _, view := range t.views {
+		view.Close()
+	}
+	t.engine.snapper.End()
+	for _, handle := range t.handles.Handles() {
+		for _, listener := range t.listeners {
+			t.removeEventListener(handle.Box(), listener)
+		}
+	}
+	t.listeners = t.listeners[:0]
+}
+
+// Start 开始状态
+func (t *BoxStretchState) Start() {
+	t.isRunning = true
+	for _, view := range t.views {
+		view.Render()
+	}
+
+	target := t.target
+	t.width = float64(target.width)
+	t.height = float64(target.height)
+	t.cx = float64(target.x) + t.width/2
+	t.cy = float64(target.y) + t.height/2
+	t.engine.snapper.Begin([]*Box{target})
+
+	for _, handle := range t.handles.Handles() {
+		if handle.State() != t.mode {
+			continue
+		}
+		h := handle
+		t.listeners = append(t.listeners, t.addEventListener(h.Box(), DRAG, func(evt MouseEvent) {
+			t.stretch(h, evt)
+		}))
+		t.listeners = append(t.listeners, t.addEventListener(h.Box(), DRAGEND, func(evt MouseEvent) {
+			t.engine.history.Seal()
+			if t.eventsHandler != nil {
+				t.eventsHandler(stretchEndEvents[t.mode])
+			}
+		}))
+	}
+}
+
+// 根据控制点的位置计算box的新尺寸 在box的局部坐标系中计算 对边保持不动
+// cx cy 为拉伸开始时box的中心 在父节点的坐标系中
+func (t *BoxStretchState) stretch(handle *ResizeHandle, evt MouseEvent) {
+	target := t.target
+	config := t.engine.config
+
+	// 控制点中心的新位置 从绝对坐标转换到父节点的坐标系 再转换到以拉伸开始时的中心为原点的局部坐标系
+	hx := float64(evt.mouseX-evt.data.x) + RESIZEHANDLESIZE/2
+	hy := float64(evt.mouseY-evt.data.y) + RESIZEHANDLESIZE/2
+	snapX, snapY := stretchSnapAxes(handle, target.GetWorldAngle())
+	hx, hy = t.engine.snapper.SnapPoint(hx, hy, snapX, snapY, evt.KeyModifiers)
+	hx, hy = target.GetParentTransform().Invert().Apply(hx, hy)
+	lx, ly := rotatePoint(-target.angle, hx, hy, t.cx, t.cy)
+	lx -= t.cx
+	ly -= t.cy
+
+	// 固定的对边
+	fx := -float64(handle.dx) * t.width / 2
+	fy := -float64(handle.dy) * t.height / 2
+
+	width, height := t.width, t.height
+	if handle.dx != 0 {
+		width = float64(handle.dx) * (lx - fx)
+	}
+	if handle.dy != 0 {
+		height = float64(handle.dy) * (ly - fy)
+	}
+	width = math.Max(width, float64(config.minWidth))
+	height = math.Max(height, float64(config.minHeight))
+	if t.mode == STRETCH && config.keepAspectRatio {
+		//宽或高为0的控件按1计算比例 避免除以0
+		w0 := math.Max(t.width, 1)
+		h0 := math.Max(t.height, 1)
+		scale := math.Max(width/w0, height/h0)
+		width = w0 * scale
+		height = h0 * scale
+	}
+
+	// 新的中心 从局部坐标系转回父节点的坐标系
+	ncx := fx + float64(handle.dx)*width/2
+	ncy := fy + float64(handle.dy)*height/2
+	cx, cy := rotatePoint(target.angle, t.cx+ncx, t.cy+ncy, t.cx, t.cy)
+
+	cmd := NewResizeBoxCommand(t.engine, target, round(cx-width/2), round(cy-height/2), round(width), round(height))
+	t.engine.history.ExecuteMerge(cmd)
+}
+
+// 拉伸时控制点吸附的方向 控件水平或竖直时只吸附控制点移动的方向 倾斜时两个方向都吸附
+func stretchSnapAxes(handle *ResizeHandle, angle float64) (bool, bool) {
+	const epsilon = 1e-6
+	if math.Abs(math.Sin(angle)) < epsilon {
+		return handle.dx != 0, handle.dy != 0
+	}
+	if math.Abs(math.Cos(angle)) < epsilon {
+		return handle.dy != 0, handle.dx != 0
+	}
+	return true, true
+}
+
+/////////////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////  BoxRotateState start ///////////////////////////////////
+/////////////////////////////////////////////////////////////////////////////////////////
+
+// BoxRotateState 旋转状态 绕box中心旋转
+type BoxRotateState struct {
+	BoxBasicState
+	rotateHandle    *BoxRotateHandleView
+	dragListener    *EventListener
+	dragendListener *EventListener
+}
+
+// NewBoxRotateState 构造函数
+func NewBoxRotateState(target *Box, handles *BoxResizeHandlesView, rotateHandle *BoxRotateHandleView, engine *Engine) (state *BoxRotateState) {
+	state = &BoxRotateState{}
+	state.initState(target, engine)
+	state.rotateHandle = rotateHandle
+	state.views = append(state.views, NewBoxBorderView(target, "selectedborder", engine), handles, rotateHandle)
+	return state
+}
+
+// Stop 停止状态
+func (t *BoxRotateState) Stop() {
+	t.isRunning = false
+	for _, view := range t.views {
+		view.Close()
+	}
+	t.removeEventListener(t.rotateHandle.Handle(), t.dragListener)
+	t.removeEventListener(t.rotateHandle.Handle(), t.dragendListener)
+	t.engine.snapper.End()
+}
+
+// Start 开始状态
+func (t *BoxRotateState) Start() {
+	t.isRunning = true
+	for _, view := range t.views {
+		view.Render()
+	}
+	handle := t.rotateHandle.Handle()
+	t.engine.snapper.Begin([]*Box{t.target})
+	t.dragListener = t.addEventListener(handle, DRAG, t.rotate)
+	t.dragendListener = t.addEventListener(handle, DRAGEND, func(evt MouseEvent) {
+		t.engine.history.Seal()
+		if t.eventsHandler != nil {
+			t.eventsHandler(ROTATEEND)
+		}
+	})
+}
+
+// 控制点在box正上方时角度为0 顺时针为正 控件的角度相对父节点 减去父节点的绝对角度
+func (t *BoxRotateState) rotate(evt MouseEvent) {
+	target := t.target
+	cx, cy := target.GetCenterPoint()
+	hx := float64(evt.mouseX-evt.data.x) + RESIZEHANDLESIZE/2
+	hy := float64(evt.mouseY-evt.data.y) + RESIZEHANDLESIZE/2
+	parentAngle := target.GetParentTransform().Angle()
+	angle := math.Atan2(hx-float64(cx), float64(cy)-hy) - parentAngle
+	angle = t.engine.snapper.SnapAngle(angle, parentAngle, math.Hypot(hx-float64(cx), hy-float64(cy)), evt.KeyModifiers)
+	t.engine.history.ExecuteMerge(NewRotateBoxCommand(t.engine, target, normalizeAngle(angle)))
 }
 
 /////////////////////////////////////////////////////////////////////////////////////////
 ///////////////////////////////  BoxMoveState start /////////////////////////////////////
 /////////////////////////////////////////////////////////////////////////////////////////
 
-// BoxMoveState 平移状态
+// BoxMoveState 平移状态 拖拽选中的控件时平移所有选中的控件
+// 拖拽时在交互层高亮鼠标下可以放入的容器 松开时把控件放入该容器 与平移合为一步历史
 type BoxMoveState struct {
 	BoxBasicState
 	dragListener    *EventListener
 	dragendListener *EventListener
+	// 拖拽开始时target的绝对坐标 以及要平移的控件和它们的开始位置
+	startX, startY int
+	boxes          []*Box
+	origins        []boxGeometry
+	rect           snapRect //拖拽开始时控件外框的并集 用于吸附
+	dropTarget     *Box
+	dropView       *BoxBorderView
 }
 
 // NewBoxMoveState 构造函数
@@ -193,6 +528,8 @@ func (t *BoxMoveState) Stop() {
 	}
 	t.removeEventListener(t.target, t.dragListener)
 	t.removeEventListener(t.target, t.dragendListener)
+	t.setDropTarget(nil)
+	t.engine.snapper.End()
 }
 
 // Start 开始状态
@@ -202,28 +539,61 @@ func (t *BoxMoveState) Start() {
 		view.Render()
 	}
 
+	t.startX, t.startY = t.target.GetPosition()
+	t.boxes = []*Box{t.target}
+	if t.target.IsSelected() {
+		t.boxes = t.engine.selectionRoots()
+	}
+	t.origins = make([]boxGeometry, 0, len(t.boxes))
+	for _, box := range t.boxes {
+		t.origins = append(t.origins, getBoxGeometry(box))
+	}
+	t.rect = boxesRect(t.boxes)
+	t.engine.snapper.Begin(t.boxes)
+
 	// drag 刷新target视图
 	t.dragListener = t.addEventListener(t.target, DRAG, func(evt MouseEvent) {
-		target := t.target
-		ob := target.GetBounds()
-		target.x = evt.mouseX - evt.data.x
-		target.y = evt.mouseY - evt.data.y
-		b := target.GetBounds()
-		for _, view := range t.views {
-			view.Refresh()
-		}
-		rect := &Rect{}
-		rect.x = intMin(ob.x, b.x)
-		rect.y = intMin(ob.y, b.y)
-		rect.width = intMax(ob.x+ob.width, b.x+b.width) - rect.x
-		rect.height = intMax(ob.y+ob.height, b.y+b.height) - rect.y
-		t.engine.render.PaintRectArea(rect, 1)
+		// 一次拖拽合并为一步历史 位移吸附到其他控件 参考线或网格
+		dx := float64(evt.mouseX - evt.data.x - t.startX)
+		dy := float64(evt.mouseY - evt.data.y - t.startY)
+		dx, dy = t.engine.snapper.SnapMove(t.rect, dx, dy, evt.KeyModifiers)
+		t.engine.history.ExecuteMerge(t.engine.newMoveBoxesCommand(t.boxes, t.origins, round(dx), round(dy)))
+		t.setDropTarget(t.engine.findDropTarget(t.boxes, evt.mouseX, evt.mouseY))
 	})
 
 	t.dragendListener = t.addEventListener(t.target, DRAGEND, func(evt MouseEvent) {
 		fmt.Println("drag end")
-		if t.eventsHandler != nil {
+		if t.dropTarget != nil {
+			if cmd := t.engine.newDropBoxesCommand(t.boxes, t.dropTarget); cmd != nil {
+				t.engine.history.ExecuteAppend(cmd)
+			}
+			t.setDropTarget(nil)
+		}
+		t.engine.history.Seal()
+		if t.eventsHandler == nil {
+			return
+		}
+		// 选中的控件移动结束后回到选中状态
+		if t.target.IsSelected() {
+			t.eventsHandler(SELECT)
+		} else {
 			t.eventsHandler(MOVEEND)
 		}
 	})
 }
+
+// 切换拖拽时高亮的容器 根节点不高亮 target为nil时清除
+func (t *BoxMoveState) setDropTarget(target *Box) {
+	if target == t.dropTarget {
+		return
+	}
+	if t.dropView != nil {
+		t.dropView.Close()
+		t.dropView = nil
+	}
+	t.dropTarget = target
+	if target != nil && target != t.engine.boxTree.GetBoxROOT() {
+		t.dropView = NewBoxBorderView(target, "droptarget", t.engine)
+		t.dropView.Render()
+	}
+}
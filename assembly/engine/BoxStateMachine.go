@@ -34,6 +34,8 @@ const (
 	OUT
 	// SELECT 选择
 	SELECT
+	// UNSELECT 取消选择
+	UNSELECT
 	// MOVESTART 开始移动
 	MOVESTART
 	// MOVEEND 结束移动
@@ -63,7 +65,14 @@ func BoxStateMachineFactroy(target *Box, engine *Engine) (machine *BoxStateMachi
 	states := make(map[BoxState]BoxStateInterface)
 	states[NORMAL] = NewBoxNormalState(target, engine)
 	states[HOVER] = NewBoxHoverState(target, engine)
+	handles := NewBoxResizeHandlesView(target, engine)
+	rotateHandle := NewBoxRotateHandleView(target, engine)
+	states[SELECTED] = NewBoxSelectedState(target, handles, rotateHandle, engine)
 	states[MOVE] = NewBoxMoveState(target, engine)
+	states[STRETCH] = NewBoxStretchState(target, STRETCH, handles, rotateHandle, engine)
+	states[HSTRETCH] = NewBoxStretchState(target, HSTRETCH, handles, rotateHandle, engine)
+	states[VSTRETCH] = NewBoxStretchState(target, VSTRETCH, handles, rotateHandle, engine)
+	states[ROTATE] = NewBoxRotateState(target, handles, rotateHandle, engine)
 	machine.AddStates(states)
 
 	events := make(map[BoxEvent]BoxState)
@@ -71,6 +80,16 @@ func BoxStateMachineFactroy(target *Box, engine *Engine) (machine *BoxStateMachi
 	events[OUT] = NORMAL
 	events[MOVESTART] = MOVE
 	events[MOVEEND] = HOVER
+	events[SELECT] = SELECTED
+	events[UNSELECT] = NORMAL
+	events[STRETCHSTART] = STRETCH
+	events[HSTRETCHSTART] = HSTRETCH
+	events[VSTRETCHSTART] = VSTRETCH
+	events[STRETCHEND] = SELECTED
+	events[HSTRETCHEND] = SELECTED
+	events[VSTRETCHEND] = SELECTED
+	events[ROTATESTART] = ROTATE
+	events[ROTATEEND] = SELECTED
 	machine.AddEvents(events)
 
 	return machine
@@ -155,6 +174,41 @@ func (t *BoxStateMachine) OpenState(state BoxState) {
 	}
 }
 
+// CurrentState 获取当前状态
+func (t *BoxStateMachine) CurrentState() BoxState {
+	return t.currentState
+}
+
+// Refresh 刷新当前状态的交互视图 比如控件被撤销操作修改之后
+func (t *BoxStateMachine) Refresh() {
+	state, hasState := t.states[t.currentState]
+	if !hasState || !state.IsRunning() {
+		return
+	}
+	for _, view := range state.Views() {
+		view.Refresh()
+	}
+}
+
+// Restart 重新开始当前状态 状态的交互视图依赖引擎的其他状态（比如单选和多选）时调用
+func (t *BoxStateMachine) Restart() {
+	t.closeCurrentState()
+	if state, hasState := t.states[t.currentState]; hasState {
+		state.Start()
+	}
+}
+
+// Release 停止当前状态 释放状态注册的事件监听和交互视图 控件删除时调用
+func (t *BoxStateMachine) Release() {
+	t.closeCurrentState()
+	t.currentState = NORMAL
+}
+
+// DispatchEvent 从外部触发行为 比如引擎修改了选中状态
+func (t *BoxStateMachine) DispatchEvent(event BoxEvent) {
+	t.eventsDispatchHandler(event)
+}
+
 func (t *BoxStateMachine) closeCurrentState() {
 	_, hasState := t.states[t.currentState]
 	if hasState {
@@ -167,4 +221,5 @@ func (t *BoxStateMachine) eventsDispatchHandler(event BoxEvent) {
 	if ok {
 		t.OpenState(t.events[event])
 	}
+	t.engine.dispatchBoxEvent(t.target, event)
 }
@@ -20,7 +20,7 @@ func NewBoxBorderView(target *Box, styleClass string, engine *Engine) (view *Box
 	view = &BoxBorderView{}
 	view.target = target
 	view.engine = engine
-	// view.interactionTarget = NewBox(0, 0, 0, 0, styleClass)
+	view.interactionTarget = NewBox(0, 0, 0, 0, styleClass)
 	return view
 }
 
@@ -34,18 +34,16 @@ func (t *BoxBorderView) Render() {
 	t.Refresh()
 	if t.interactionTarget != nil {
 		t.engine.boxTree.AddInteractionBox(t.interactionTarget, t.engine.boxTree.GetInteractionROOT())
-		t.engine.render.PaintBox(t.target, 1)
+		t.engine.render.PaintBox(t.target)
 	}
 }
 
 // Refresh 刷新
 func (t *BoxBorderView) Refresh() {
 	if t.interactionTarget != nil {
-		px, py := t.target.GetPosition()
-		t.interactionTarget.x = px
-		t.interactionTarget.y = py
 		t.interactionTarget.width = t.target.width
 		t.interactionTarget.height = t.target.height
+		alignInteractionBox(t.interactionTarget, t.target, float64(t.target.width)/2, float64(t.target.height)/2)
 	}
 }
 
@@ -53,6 +51,157 @@ func (t *BoxBorderView) Refresh() {
 func (t *BoxBorderView) Close() {
 	if t.interactionTarget != nil {
 		t.engine.boxTree.RemoveInteractionBox(t.interactionTarget)
-		t.engine.render.PaintBox(t.target, 1)
+		t.engine.render.PaintBox(t.target)
 	}
 }
+
+// 交互层的控件在绝对坐标系中 把它的中心放到目标控件局部坐标(lx, ly)处 角度为目标的绝对角度
+func alignInteractionBox(box *Box, target *Box, lx, ly float64) {
+	m := target.GetTransform()
+	cx, cy := m.Apply(lx, ly)
+	box.x = round(cx - float64(box.width)/2)
+	box.y = round(cy - float64(box.height)/2)
+	box.angle = m.Angle()
+}
+
+// RESIZEHANDLESIZE 缩放控制点的边长
+const RESIZEHANDLESIZE = 8
+
+// ResizeHandle 缩放控制点 dx dy 表示控制点在box上的方位 -1 左/上 0 中 1 右/下
+type ResizeHandle struct {
+	box *Box
+	dx  int
+	dy  int
+}
+
+// Box 控制点在交互层的控件
+func (t *ResizeHandle) Box() *Box {
+	return t.box
+}
+
+// State 拖拽控制点对应的拉伸状态 四角等比拉伸 左右水平拉伸 上下垂直拉伸
+func (t *ResizeHandle) State() BoxState {
+	if t.dx != 0 && t.dy != 0 {
+		return STRETCH
+	} else if t.dx != 0 {
+		return HSTRETCH
+	}
+	return VSTRETCH
+}
+
+// BoxResizeHandlesView 交互层的八个缩放控制点
+type BoxResizeHandlesView struct {
+	target  *Box
+	engine  *Engine
+	handles []*ResizeHandle
+}
+
+// NewBoxResizeHandlesView 构造函数
+func NewBoxResizeHandlesView(target *Box, engine *Engine) (view *BoxResizeHandlesView) {
+	view = &BoxResizeHandlesView{}
+	view.target = target
+	view.engine = engine
+	view.handles = make([]*ResizeHandle, 0, 8)
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			box := NewBox(0, 0, RESIZEHANDLESIZE, RESIZEHANDLESIZE, "resizehandle")
+			view.handles = append(view.handles, &ResizeHandle{box, dx, dy})
+		}
+	}
+	return view
+}
+
+// Target 获取目标
+func (t *BoxResizeHandlesView) Target() *Box {
+	return t.target
+}
+
+// Handles 获取所有控制点
+func (t *BoxResizeHandlesView) Handles() []*ResizeHandle {
+	return t.handles
+}
+
+// Render 渲染视图
+func (t *BoxResizeHandlesView) Render() {
+	t.Refresh()
+	for _, handle := range t.handles {
+		t.engine.boxTree.AddInteractionBox(handle.box, t.engine.boxTree.GetInteractionROOT())
+	}
+	t.paint()
+}
+
+// Refresh 刷新 控制点跟随box的旋转落在局部坐标轴上
+func (t *BoxResizeHandlesView) Refresh() {
+	target := t.target
+	for _, handle := range t.handles {
+		lx := float64((1+handle.dx)*target.width) / 2
+		ly := float64((1+handle.dy)*target.height) / 2
+		alignInteractionBox(handle.box, target, lx, ly)
+	}
+}
+
+// Close 关闭渲染
+func (t *BoxResizeHandlesView) Close() {
+	for _, handle := range t.handles {
+		t.engine.boxTree.RemoveInteractionBox(handle.box)
+	}
+	t.paint()
+}
+
+// 重绘控制点所在区域 控制点超出了box的bounds
+func (t *BoxResizeHandlesView) paint() {
+	for _, handle := range t.handles {
+		t.engine.render.PaintBox(handle.box)
+	}
+}
+
+// ROTATEHANDLEOFFSET 旋转控制点到box上边缘的距离
+const ROTATEHANDLEOFFSET = 20
+
+// BoxRotateHandleView 交互层的旋转控制点 位于box上方 跟随box旋转
+type BoxRotateHandleView struct {
+	target *Box
+	engine *Engine
+	handle *Box
+}
+
+// NewBoxRotateHandleView 构造函数
+func NewBoxRotateHandleView(target *Box, engine *Engine) (view *BoxRotateHandleView) {
+	view = &BoxRotateHandleView{}
+	view.target = target
+	view.engine = engine
+	view.handle = NewBox(0, 0, RESIZEHANDLESIZE, RESIZEHANDLESIZE, "rotatehandle")
+	return view
+}
+
+// Target 获取目标
+func (t *BoxRotateHandleView) Target() *Box {
+	return t.target
+}
+
+// Handle 控制点在交互层的控件
+func (t *BoxRotateHandleView) Handle() *Box {
+	return t.handle
+}
+
+// Render 渲染视图
+func (t *BoxRotateHandleView) Render() {
+	t.Refresh()
+	t.engine.boxTree.AddInteractionBox(t.handle, t.engine.boxTree.GetInteractionROOT())
+	t.engine.render.PaintBox(t.handle)
+}
+
+// Refresh 刷新
+func (t *BoxRotateHandleView) Refresh() {
+	target := t.target
+	alignInteractionBox(t.handle, target, float64(target.width)/2, -ROTATEHANDLEOFFSET)
+}
+
+// Close 关闭渲染
+func (t *BoxRotateHandleView) Close() {
+	t.engine.boxTree.RemoveInteractionBox(t.handle)
+	t.engine.render.PaintBox(t.handle)
+}
@@ -1,27 +1,56 @@
 package main
 
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
 // ROOT 根
 const ROOT = 0
 
+// ROOTID 根节点的id
+const ROOTID = "root"
+
+// 生成控件id的随机数 控件只在主线程中创建
+var boxIDSource = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// 生成新的控件id 12位十六进制
+func newBoxID() string {
+	return fmt.Sprintf("%012x", boxIDSource.Int63()&0xffffffffffff)
+}
+
+// GROUPCLASS 组合的类名 组合是一个容器 成员作为整体被选中 移动 旋转和拉伸
+const GROUPCLASS = "group"
+
 //Box 控件
 type Box struct {
-	x          int
-	y          int
-	width      int
-	height     int
-	angle      float64
-	styleClass string
-	parent     *Box
-	isSelected bool
-	isCorrect  bool
-	isUsed     bool
-	canBubble  bool   //是否继续冒泡
-	children   []*Box //子节点 按照z-index排序
+	id          string //唯一标识 创建后不变 保存在文档中
+	name        string //名称 由用户设置 可以重复
+	x           int
+	y           int
+	width       int
+	height      int
+	angle       float64
+	styleClass  string     //多个类用空格分隔
+	inlineStyle *StyleRule //内联样式 覆盖类的样式
+	parent      *Box
+	isSelected  bool
+	isHovering  bool //鼠标悬停 用于样式的:hover
+	isCorrect   bool
+	isUsed      bool
+	canBubble   bool   //是否继续冒泡
+	zIndex      int    //在父节点children中的位置
+	children    []*Box //子节点 按照z-index排序
 }
 
 // NewBox 构造函数
 func NewBox(x, y, width, height int, styleClass string) (box *Box) {
 	box = &Box{}
+	box.id = newBoxID()
 	box.x = x
 	box.y = y
 	box.width = width
@@ -39,6 +68,31 @@ func NewBox(x, y, width, height int, styleClass string) (box *Box) {
 	return box
 }
 
+// GetID 获取控件id
+func (t *Box) GetID() string {
+	return t.id
+}
+
+// GetName 获取控件名称
+func (t *Box) GetName() string {
+	return t.name
+}
+
+// GetClasses 获取控件的所有类
+func (t *Box) GetClasses() []string {
+	return strings.Fields(t.styleClass)
+}
+
+// IsGroup 是否是组合
+func (t *Box) IsGroup() bool {
+	for _, class := range t.GetClasses() {
+		if class == GROUPCLASS {
+			return true
+		}
+	}
+	return false
+}
+
 // IsSelected getter 选中状态
 func (t *Box) IsSelected() bool {
 	return t.isSelected
@@ -52,7 +106,7 @@ func (t *Box) SetIsSelected(status bool) {
 	t.isSelected = status
 }
 
-// GetPosition 获取绝对位置坐标
+// GetPosition 获取绝对位置坐标 只累加父节点的x y 不考虑旋转 需要精确的绝对坐标时使用GetTransform
 func (t *Box) GetPosition() (int, int) {
 	b := t
 	x := b.x
@@ -71,36 +125,70 @@ func (t *Box) GetPosition() (int, int) {
 	return x, y
 }
 
-// GetBounds 获取bounds
-func (t *Box) GetBounds() (bounds Bounds) {
-	bounds = Bounds{}
-
-	px, py := t.GetPosition()
+// GetTransform 获取从控件局部坐标到绝对坐标的变换
+// 局部坐标以控件左上角为原点 控件绕自身中心旋转 父节点的变换作用于子节点
+func (t *Box) GetTransform() Matrix {
+	local := TranslateMatrix(float64(t.x), float64(t.y))
+	if t.angle != 0 {
+		local = local.Multiply(RotateAboutMatrix(t.angle, float64(t.width)/2, float64(t.height)/2))
+	}
+	if t.parent == nil {
+		return local
+	}
+	return t.parent.GetTransform().Multiply(local)
+}
 
-	if t.angle == 0 {
-		bounds.x = px
-		bounds.y = py
-		bounds.width = t.width
-		bounds.height = t.height
-	} else {
-		cx, cy := t.x+t.width/2, t.y+t.height/2
+// GetParentTransform 获取父节点的变换 即控件的x y所在坐标系到绝对坐标的变换 没有父节点时为单位矩阵
+func (t *Box) GetParentTransform() Matrix {
+	if t.parent == nil {
+		return IdentityMatrix()
+	}
+	return t.parent.GetTransform()
+}
 
-		x1, y1 := px, py
-		x2, y2 := px+t.width, py
-		x3, y3 := px+t.width, py+t.height
-		x4, y4 := px, py+t.height
+// GetWorldAngle 获取绝对角度 包含所有父节点的旋转
+func (t *Box) GetWorldAngle() float64 {
+	return t.GetTransform().Angle()
+}
 
-		rx1, ry1 := srotate(t.angle, x1, y1, cx, cy)
-		rx2, ry2 := srotate(t.angle, x2, y2, cx, cy)
-		rx3, ry3 := srotate(t.angle, x3, y3, cx, cy)
-		rx4, ry4 := srotate(t.angle, x4, y4, cx, cy)
+// GetCorners 获取四个角的绝对坐标 顺序为 左上 右上 右下 左下
+func (t *Box) GetCorners() []Point {
+	m := t.GetTransform()
+	w := float64(t.width)
+	h := float64(t.height)
+	corners := make([]Point, 4)
+	corners[0].x, corners[0].y = m.Apply(0, 0)
+	corners[1].x, corners[1].y = m.Apply(w, 0)
+	corners[2].x, corners[2].y = m.Apply(w, h)
+	corners[3].x, corners[3].y = m.Apply(0, h)
+	return corners
+}
 
-		bounds.x = intMin(rx1, rx2, rx3, rx4) - 2
-		bounds.y = intMin(ry1, ry2, ry3, ry4) - 2
+// GetBounds 获取bounds 包含旋转后的四个角
+func (t *Box) GetBounds() (bounds Bounds) {
+	bounds = Bounds{}
 
-		bounds.width = intMax(rx1, rx2, rx3, rx4) - bounds.x + 2
-		bounds.height = intMax(ry1, ry2, ry3, ry4) - bounds.y + 2
+	corners := t.GetCorners()
+	minX, minY := corners[0].x, corners[0].y
+	maxX, maxY := minX, minY
+	for _, p := range corners[1:] {
+		minX = math.Min(minX, p.x)
+		minY = math.Min(minY, p.y)
+		maxX = math.Max(maxX, p.x)
+		maxY = math.Max(maxY, p.y)
+	}
 
+	bounds.x = int(math.Floor(minX))
+	bounds.y = int(math.Floor(minY))
+	bounds.width = int(math.Ceil(maxX)) - bounds.x
+	bounds.height = int(math.Ceil(maxY)) - bounds.y
+
+	//旋转后的边缘有抗锯齿 多留出一些
+	if corners[0].y != corners[1].y {
+		bounds.x -= 2
+		bounds.y -= 2
+		bounds.width += 4
+		bounds.height += 4
 	}
 
 	return bounds
@@ -108,10 +196,14 @@ func (t *Box) GetBounds() (bounds Bounds) {
 
 // GetCenterPoint 获取box原点
 func (t *Box) GetCenterPoint() (x int, y int) {
-	px, py := t.GetPosition()
-	x = round(float64(t.x) + float64(t.width)/2)
-	y = round(float64(t.y) + float64(t.height)/2)
-	return x + px, y + py
+	cx, cy := t.GetTransform().Apply(float64(t.width)/2, float64(t.height)/2)
+	return round(cx), round(cy)
+}
+
+// ContainsPoint 判断绝对坐标的点是否在控件内 考虑控件和父节点的旋转
+func (t *Box) ContainsPoint(x, y float64) bool {
+	lx, ly := t.GetTransform().Invert().Apply(x, y)
+	return lx > 0 && lx < float64(t.width) && ly > 0 && ly < float64(t.height)
 }
 
 //Bounds 元素外框
@@ -127,31 +219,104 @@ type Position struct {
 	x, y int
 }
 
+// ZOrderOperation 层级调整
+type ZOrderOperation int
+
+const (
+	// BRINGTOFRONT 置于顶层
+	BRINGTOFRONT ZOrderOperation = iota
+	// SENDTOBACK 置于底层
+	SENDTOBACK
+	// BRINGFORWARD 上移一层
+	BRINGFORWARD
+	// SENDBACKWARD 下移一层
+	SENDBACKWARD
+)
+
 // BoxTree box树
 type BoxTree struct {
-	boxeslist            []*Box
+	boxeslist            []*Box //按绘制顺序（深度遍历children）排列的所有控件 根节点在最前
 	interactionBoxeslist []*Box
+	index                *SpatialIndex   //控件层的空间索引 不包括根节点
+	listDirty            bool            //boxeslist需要按绘制顺序重新排列
+	ids                  map[string]*Box //id到控件的索引 包括根节点
 }
 
 // NewBoxTree 构造函数
 func NewBoxTree(width, height int) (tree *BoxTree) {
 	tree = &BoxTree{}
+	tree.index = NewSpatialIndex(Bounds{0, 0, width, height})
 	tree.boxeslist = make([]*Box, 0)
 	tree.interactionBoxeslist = make([]*Box, 0)
+	tree.ids = make(map[string]*Box)
 	boxROOT := NewBox(0, 0, width, height, "")
+	boxROOT.id = ROOTID
 	tree.AddBox(boxROOT, nil)
 	interactionROOT := NewBox(0, 0, width, height, "")
 	tree.AddInteractionBox(interactionROOT, nil)
 	return tree
 }
 
+// SetSize 修改舞台尺寸 空间索引为空时按新的舞台范围重建
+func (t *BoxTree) SetSize(width, height int) {
+	root := t.boxeslist[ROOT]
+	root.width, root.height = width, height
+	interactionROOT := t.interactionBoxeslist[ROOT]
+	interactionROOT.width, interactionROOT.height = width, height
+	if t.index.Len() == 0 {
+		t.index = NewSpatialIndex(Bounds{root.x, root.y, width, height})
+	}
+}
+
 // GetBoxROOT 获取控件根节点
 func (t *BoxTree) GetBoxROOT() *Box {
 	return t.boxeslist[ROOT]
 }
 
-// GetBoxlist 回去控件列表
+// GetBoxByID 按id获取控件 不在boxtree中的控件返回nil
+func (t *BoxTree) GetBoxByID(id string) *Box {
+	return t.ids[id]
+}
+
+// FindBoxesByName 按名称查找控件 按绘制顺序排列
+func (t *BoxTree) FindBoxesByName(name string) []*Box {
+	result := make([]*Box, 0)
+	for _, box := range t.GetBoxlist() {
+		if box.name == name && box.parent != nil {
+			result = append(result, box)
+		}
+	}
+	return result
+}
+
+// 把控件（连同子控件）加入id索引 没有id或id已被其他控件使用时生成新的id 比如新建或粘贴的控件
+// 文档中的重复id在Document.Build中报错
+func (t *BoxTree) registerBox(box *Box) {
+	for _, b := range append([]*Box{box}, getDescendants(box)...) {
+		for b.id == "" || (t.ids[b.id] != nil && t.ids[b.id] != b) {
+			b.id = newBoxID()
+		}
+		t.ids[b.id] = b
+	}
+}
+
+// 把控件（连同子控件）从id索引中移除
+func (t *BoxTree) unregisterBox(box *Box) {
+	for _, b := range append([]*Box{box}, getDescendants(box)...) {
+		if t.ids[b.id] == b {
+			delete(t.ids, b.id)
+		}
+	}
+}
+
+// GetBoxlist 获取控件列表 按绘制顺序排列
 func (t *BoxTree) GetBoxlist() []*Box {
+	if t.listDirty {
+		root := t.boxeslist[ROOT]
+		t.boxeslist = append(t.boxeslist[:0], root)
+		t.boxeslist = append(t.boxeslist, getDescendants(root)...)
+		t.listDirty = false
+	}
 	return t.boxeslist
 }
 
@@ -172,33 +337,338 @@ func (t *BoxTree) AddBox(box *Box, parent *Box) {
 		box.parent = parent
 		children := box.parent.children
 		box.parent.children = append(children, box)
+		box.zIndex = len(box.parent.children) - 1
 	}
 	t.boxeslist = append(boxlist, box)
+	t.registerBox(box)
+
+	if parent != nil {
+		t.index.Insert(box)
+		t.listDirty = true
+	}
+}
+
+// InsertBox 把box（连同子树）插入到容器children的index位置 index越界时放到最上层
+func (t *BoxTree) InsertBox(box *Box, parent *Box, index int) {
+	if index < 0 || index > len(parent.children) {
+		index = len(parent.children)
+	}
+	box.parent = parent
+	children := append(parent.children, nil)
+	copy(children[index+1:], children[index:])
+	children[index] = box
+	parent.children = children
+	reindexChildren(parent, index)
+
+	t.boxeslist = append(t.boxeslist, box)
+	t.boxeslist = append(t.boxeslist, getDescendants(box)...)
+	t.listDirty = true
+	t.registerBox(box)
+	t.UpdateBox(box)
+}
+
+// RemoveBox 把box（连同子树）从boxtree中移除 子树内部的关系保持不变
+func (t *BoxTree) RemoveBox(box *Box) {
+	if box.parent == nil {
+		return
+	}
+	removed := make(map[*Box]bool)
+	removed[box] = true
+	t.index.Remove(box)
+	for _, b := range getDescendants(box) {
+		removed[b] = true
+		t.index.Remove(b)
+	}
+	list := t.boxeslist[:0]
+	for _, b := range t.boxeslist {
+		if !removed[b] {
+			list = append(list, b)
+		}
+	}
+	for idx := len(list); idx < len(t.boxeslist); idx++ {
+		t.boxeslist[idx] = nil
+	}
+	t.boxeslist = list
+	t.unregisterBox(box)
+
+	parent := box.parent
+	parent.children = removeBoxFromList(parent.children, box)
+	reindexChildren(parent, box.zIndex)
+	box.parent = nil
+}
+
+// UpdateBox 控件的位置 尺寸或角度变化后更新空间索引 子控件跟随更新
+func (t *BoxTree) UpdateBox(box *Box) {
+	if box.parent == nil {
+		return
+	}
+	t.index.Update(box)
+	for _, b := range getDescendants(box) {
+		t.index.Update(b)
+	}
+}
+
+// GetSubtreeBounds 控件和所有子控件的外框的并集
+func (t *BoxTree) GetSubtreeBounds(box *Box) Bounds {
+	bounds := box.GetBounds()
+	for _, b := range getDescendants(box) {
+		r := unionBounds(bounds, b.GetBounds(), 0)
+		bounds = Bounds{r.x, r.y, r.width, r.height}
+	}
+	return bounds
+}
+
+// QueryPoint 查找外框包含该点的控件 结果无序 需要再用IsPointInBox精确判断
+func (t *BoxTree) QueryPoint(x, y int) []*Box {
+	return t.index.QueryPoint(x, y)
+}
+
+// QueryRect 查找外框与矩形相交的控件 结果无序
+func (t *BoxTree) QueryRect(rect Bounds) []*Box {
+	return t.index.QueryRect(rect)
+}
+
+// QueryMarquee 框选容器（比如根节点或进入的组合）中的控件 按照z-index排序
+// contain 为true时只返回完全在矩形内的控件 否则返回与矩形相交的控件
+func (t *BoxTree) QueryMarquee(container *Box, rect Bounds, contain bool) []*Box {
+	x1, y1 := float64(rect.x), float64(rect.y)
+	x2, y2 := float64(rect.x+rect.width), float64(rect.y+rect.height)
+	polygon := []Point{{x1, y1}, {x2, y1}, {x2, y2}, {x1, y2}}
+	list := make([]*Box, 0)
+	for _, box := range t.QueryRect(rect) {
+		if box.parent != container || !isBoxUsed(box) {
+			continue
+		}
+		if contain {
+			inside := true
+			for _, p := range box.GetCorners() {
+				if p.x < x1 || p.x > x2 || p.y < y1 || p.y > y2 {
+					inside = false
+					break
+				}
+			}
+			if inside {
+				list = append(list, box)
+			}
+		} else if polygonsOverlap(polygon, box.GetCorners()) {
+			list = append(list, box)
+		}
+	}
+	t.SortByZOrder(list)
+	return list
+}
+
+// SortByZOrder 按照绘制顺序排序 先绘制的在前 父节点在子节点之前
+func (t *BoxTree) SortByZOrder(list []*Box) {
+	paths := make(map[*Box][]int, len(list))
+	for _, box := range list {
+		paths[box] = getZPath(box)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		p1 := paths[list[i]]
+		p2 := paths[list[j]]
+		for k := 0; k < len(p1) && k < len(p2); k++ {
+			if p1[k] != p2[k] {
+				return p1[k] < p2[k]
+			}
+		}
+		return len(p1) < len(p2)
+	})
+}
+
+// IndexOfChild 返回box在父节点children中的位置 即z-index
+func (t *BoxTree) IndexOfChild(box *Box) int {
+	if box.parent == nil {
+		return -1
+	}
+	return box.zIndex
+}
+
+// SetZIndex 调整box在兄弟节点中的层级
+func (t *BoxTree) SetZIndex(box *Box, index int) {
+	parent := box.parent
+	if parent == nil {
+		return
+	}
+	children := removeBoxFromList(parent.children, box)
+	if index < 0 || index > len(children) {
+		index = len(children)
+	}
+	children = append(children, nil)
+	copy(children[index+1:], children[index:])
+	children[index] = box
+	parent.children = children
+	reindexChildren(parent, 0)
+	t.listDirty = true
+}
+
+// Reorder 调整一组兄弟控件的层级 控件之间的相对顺序不变 不同容器中的控件分别调整 返回是否有变化
+func (t *BoxTree) Reorder(boxes []*Box, op ZOrderOperation) bool {
+	changed := false
+	for parent, children := range t.ReorderedChildren(boxes, op) {
+		t.SetChildren(parent, children)
+		changed = true
+	}
+	return changed
+}
+
+// ReorderedChildren 计算调整层级后容器children的新顺序 不修改boxtree 只返回有变化的容器
+// 上移一层时与上方相邻的未选中兄弟交换 被上方选中的控件挡住时不动 下移同理
+func (t *BoxTree) ReorderedChildren(boxes []*Box, op ZOrderOperation) map[*Box][]*Box {
+	moving := make(map[*Box]bool)
+	seen := make(map[*Box]bool)
+	parents := make([]*Box, 0)
+	for _, box := range boxes {
+		if box.parent == nil {
+			continue
+		}
+		moving[box] = true
+		if !seen[box.parent] {
+			seen[box.parent] = true
+			parents = append(parents, box.parent)
+		}
+	}
+
+	result := make(map[*Box][]*Box)
+	for _, parent := range parents {
+		children := make([]*Box, 0, len(parent.children))
+		switch op {
+		case BRINGTOFRONT, SENDTOBACK:
+			selected := make([]*Box, 0)
+			others := make([]*Box, 0)
+			for _, b := range parent.children {
+				if moving[b] {
+					selected = append(selected, b)
+				} else {
+					others = append(others, b)
+				}
+			}
+			if op == BRINGTOFRONT {
+				children = append(append(children, others...), selected...)
+			} else {
+				children = append(append(children, selected...), others...)
+			}
+		case BRINGFORWARD:
+			children = append(children, parent.children...)
+			for i := len(children) - 2; i >= 0; i-- {
+				if moving[children[i]] && !moving[children[i+1]] {
+					children[i], children[i+1] = children[i+1], children[i]
+				}
+			}
+		case SENDBACKWARD:
+			children = append(children, parent.children...)
+			for i := 1; i < len(children); i++ {
+				if moving[children[i]] && !moving[children[i-1]] {
+					children[i], children[i-1] = children[i-1], children[i]
+				}
+			}
+		}
+		for idx, b := range children {
+			if parent.children[idx] != b {
+				result[parent] = children
+				break
+			}
+		}
+	}
+	return result
+}
+
+// SetChildren 按新的顺序排列容器的children children必须是原来children的重新排列
+func (t *BoxTree) SetChildren(parent *Box, children []*Box) {
+	parent.children = append(parent.children[:0], children...)
+	reindexChildren(parent, 0)
+	t.listDirty = true
+}
 
+// Group 把一组兄弟控件放入组合 组合的大小为成员外框的并集（父节点坐标系中） 不旋转
+// 组合放在最上层成员原来的位置 成员保持原来的相对顺序 坐标改为相对组合 返回是否成功
+func (t *BoxTree) Group(boxes []*Box, group *Box) bool {
+	if len(boxes) == 0 || group.parent != nil || len(group.children) > 0 {
+		return false
+	}
+	parent := boxes[0].parent
+	if parent == nil {
+		return false
+	}
+	for _, box := range boxes {
+		if box.parent != parent || box == group {
+			return false
+		}
+	}
+	members := append([]*Box{}, boxes...)
+	t.SortByZOrder(members)
+
+	inverse := parent.GetTransform().Invert()
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, box := range members {
+		for _, p := range box.GetCorners() {
+			x, y := inverse.Apply(p.x, p.y)
+			minX = math.Min(minX, x)
+			minY = math.Min(minY, y)
+			maxX = math.Max(maxX, x)
+			maxY = math.Max(maxY, y)
+		}
+	}
+	group.x = int(math.Floor(minX))
+	group.y = int(math.Floor(minY))
+	group.width = int(math.Ceil(maxX)) - group.x
+	group.height = int(math.Ceil(maxY)) - group.y
+	group.angle = 0
+
+	//成员移走后 最上层成员的位置向下移动了 len-1 个
+	index := members[len(members)-1].zIndex - (len(members) - 1)
+	for _, box := range members {
+		t.RemoveBox(box)
+	}
+	t.InsertBox(group, parent, index)
+	for _, box := range members {
+		box.x -= group.x
+		box.y -= group.y
+		t.InsertBox(box, group, -1)
+	}
+	return true
+}
+
+// Ungroup 解散组合 成员按原来的顺序放回组合所在的位置 保持绝对位置和角度 组合从boxtree中移除 返回成员
+func (t *BoxTree) Ungroup(group *Box) []*Box {
+	parent := group.parent
+	if parent == nil {
+		return nil
+	}
+	index := group.zIndex
+	members := append([]*Box{}, group.children...)
+	geometries := make([]boxGeometry, len(members))
+	for idx, box := range members {
+		geometries[idx] = reparentGeometry(box, parent)
+	}
+	t.RemoveBox(group)
+	for idx, box := range members {
+		t.RemoveBox(box)
+		box.x = geometries[idx].x
+		box.y = geometries[idx].y
+		box.angle = geometries[idx].angle
+		t.InsertBox(box, parent, index+idx)
+	}
+	return members
 }
 
 // DisableBox 禁用控件
 func (t *BoxTree) DisableBox(box *Box) {
 	//设置为不可用
 	box.isUsed = false
-	boxeslist := t.GetBoxlist()
-	for _, b := range boxeslist {
-		//将以本控件为容器的控件设置为不可用
-		if b.parent == box {
-			t.DisableBox(b)
-		}
+	//将以本控件为容器的控件设置为不可用
+	for _, b := range box.children {
+		t.DisableBox(b)
 	}
 }
 
 // EnableBox 恢复控件
 func (t *BoxTree) EnableBox(box *Box) {
 	box.isUsed = true
-	boxeslist := t.GetBoxlist()
-	for _, b := range boxeslist {
-		//将以本控件为容器的控件设置为不可用
-		if b.parent == box {
-			t.EnableBox(b)
-		}
+	//将以本控件为容器的控件设置为可用
+	for _, b := range box.children {
+		t.EnableBox(b)
 	}
 }
 
@@ -231,6 +701,10 @@ func (t *BoxTree) AddInteractionBox(box *Box, parent *Box) {
 
 // RemoveInteractionBox 删除交互层控件
 func (t *BoxTree) RemoveInteractionBox(box *Box) {
+	if box.parent != nil {
+		box.parent.children = removeBoxFromList(box.parent.children, box)
+		box.parent = nil
+	}
 	idx := 0
 	for idx < len(t.interactionBoxeslist) {
 		if t.interactionBoxeslist[idx] == box {
@@ -251,16 +725,14 @@ func (t *BoxTree) GetAllPrevAndNext(box *Box) []*Box {
 	if box.parent == nil {
 		return make([]*Box, 0, 0)
 	}
-	list := t.getChildren(box.parent, false)
-	var i int
-	for k, v := range list {
-		if v == box {
-			i = k
-			break
+	siblings := box.parent.children
+	result := make([]*Box, 0, len(siblings))
+	for _, v := range siblings {
+		//去重自身
+		if v != box {
+			result = append(result, v)
 		}
 	}
-	//去重自身
-	result := append(list[:i], list[:(i+1)]...)
 	return result
 }
 
@@ -269,41 +741,101 @@ func (t *BoxTree) GetBox(idx int) *Box {
 	return t.GetBoxlist()[idx]
 }
 
-// ResetIsCorrect 重新计算合法性
+// ResetIsCorrect 重新计算合法性 只和外框相交的兄弟节点做碰撞检测
 func (t *BoxTree) ResetIsCorrect(box *Box) {
-	box.isCorrect = !t.hitTestBoxToAllBoxes(box, t.GetAllPrevAndNext(box))
+	siblings := make([]*Box, 0)
+	for _, b := range t.QueryRect(box.GetBounds()) {
+		if b != box && b.parent == box.parent {
+			siblings = append(siblings, b)
+		}
+	}
+	box.isCorrect = !t.hitTestBoxToAllBoxes(box, siblings)
 }
 
-// IsPointInBox 判断点是否碰撞Box
+// IsPointInBox 判断点是否碰撞Box 考虑旋转
 func (t *BoxTree) IsPointInBox(x, y int, box *Box) bool {
-	px, py := box.GetPosition()
-	width := box.width
-	height := box.height
-
-	return x > px && x < px+width && y > py && y < py+height
+	return box.ContainsPoint(float64(x), float64(y))
 }
 
-// HitTestBoxToBox hittest
+// HitTestBoxToBox hittest 旋转后的矩形用分离轴检测
 func (t *BoxTree) HitTestBoxToBox(box1, box2 *Box) bool {
-	return t.hitTestBoundsToBounds(box1.GetBounds(), box2.GetBounds())
+	if !t.hitTestBoundsToBounds(box1.GetBounds(), box2.GetBounds()) {
+		return false
+	}
+	return polygonsOverlap(box1.GetCorners(), box2.GetCorners())
 }
 
 // 找出所有子节点 deep 是否深度遍历
 func (t *BoxTree) getChildren(box *Box, deep bool) []*Box {
-	children := make([]*Box, 0)
-	boxeslist := t.GetBoxlist()
-	for _, p := range boxeslist {
-		if p.parent == box {
-			children = append(children, p)
-			if !deep {
-				continue
-			}
-			children = append(children, t.getChildren(box, true)...)
-		}
+	if deep {
+		return getDescendants(box)
 	}
+	children := make([]*Box, len(box.children))
+	copy(children, box.children)
 	return children
 }
 
+// 按children深度遍历出所有后代节点 不依赖boxeslist 可用于已经移出boxtree的子树
+func getDescendants(box *Box) []*Box {
+	list := make([]*Box, 0)
+	for _, child := range box.children {
+		list = append(list, child)
+		list = append(list, getDescendants(child)...)
+	}
+	return list
+}
+
+// ancestor 是否是 box 的祖先
+func isAncestor(ancestor *Box, box *Box) bool {
+	for b := box.parent; b != nil; b = b.parent {
+		if b == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// 控件和它的所有父节点都可用
+func isBoxUsed(box *Box) bool {
+	for b := box; b != nil; b = b.parent {
+		if !b.isUsed {
+			return false
+		}
+	}
+	return true
+}
+
+// 重新记录children从from开始的z-index
+func reindexChildren(parent *Box, from int) {
+	for idx := intMax(from, 0); idx < len(parent.children); idx++ {
+		parent.children[idx].zIndex = idx
+	}
+}
+
+// 从根节点到box每一级的z-index
+func getZPath(box *Box) []int {
+	deep := 0
+	for b := box; b.parent != nil; b = b.parent {
+		deep++
+	}
+	path := make([]int, deep)
+	for b := box; b.parent != nil; b = b.parent {
+		deep--
+		path[deep] = b.zIndex
+	}
+	return path
+}
+
+// 从列表中删除box 返回新的列表
+func removeBoxFromList(list []*Box, box *Box) []*Box {
+	for idx, val := range list {
+		if val == box {
+			return append(list[:idx], list[idx+1:]...)
+		}
+	}
+	return list
+}
+
 func (t *BoxTree) parseBoolToUint8(value bool) uint8 {
 	if value == true {
 		return uint8(1)
@@ -312,7 +844,7 @@ func (t *BoxTree) parseBoolToUint8(value bool) uint8 {
 }
 
 func (t *BoxTree) hitTestBoundsToBounds(bounds1, bounds2 Bounds) bool {
-	return intAbs((bounds1.x+bounds1.width/2)-(bounds2.x-bounds2.width/2)) < (bounds1.width+bounds2.width)/2 && intAbs((bounds1.y+bounds1.height/2)-(bounds2.y-bounds2.height/2)) < (bounds1.height+bounds2.height)/2
+	return boundsIntersect(bounds1, bounds2)
 }
 
 // 控件和所有元素hitTest
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// 创建测试用的boxtree 父控件(100,100,200,100)旋转90° 中心为(200,150)
+// 父控件内的点(px,py)对应绝对坐标(250-py, 50+px)
+func newRotatedParentTree() (*BoxTree, *Box) {
+	tree := NewBoxTree(1000, 1000)
+	parent := NewBox(100, 100, 200, 100, "")
+	parent.angle = math.Pi / 2
+	tree.AddBox(parent, tree.GetBoxROOT())
+	return tree, parent
+}
+
+func TestContainsPoint(t *testing.T) {
+	tree, parent := newRotatedParentTree()
+	// 在父控件中占 (0,0)-(20,20) 绝对坐标为 x 230..250 y 50..70
+	child := NewBox(0, 0, 20, 20, "")
+	tree.AddBox(child, parent)
+	// 自身也旋转90° 在父控件中占 x 10..30 y -10..30 绝对坐标为 x 220..260 y 60..80
+	rotated := NewBox(0, 0, 40, 20, "")
+	rotated.angle = math.Pi / 2
+	tree.AddBox(rotated, parent)
+	// 旋转90° 绝对坐标为 x 40..60 y -40..60
+	bar := NewBox(0, 0, 100, 20, "")
+	bar.angle = math.Pi / 2
+	tree.AddBox(bar, tree.GetBoxROOT())
+
+	cases := []struct {
+		name string
+		box  *Box
+		x, y float64
+		want bool
+	}{
+		{"旋转的控件内", bar, 50, 50, true},
+		{"旋转前的范围内", bar, 90, 10, false},
+		{"旋转的控件边上", bar, 60, 0, false},
+		{"父控件旋转 中心", child, 240, 60, true},
+		{"父控件旋转 未旋转时的位置", child, 110, 110, false},
+		{"父控件旋转 边上", child, 250, 60, false},
+		{"父控件和自身都旋转", rotated, 255, 70, true},
+		{"父控件和自身都旋转 自身旋转前的范围", rotated, 240, 55, false},
+		{"父控件旋转 父控件内", parent, 240, 200, true},
+		{"父控件旋转 旋转前的范围", parent, 120, 110, false},
+	}
+	for _, c := range cases {
+		if got := c.box.ContainsPoint(c.x, c.y); got != c.want {
+			t.Errorf("%s: ContainsPoint(%v, %v) = %v, want %v", c.name, c.x, c.y, got, c.want)
+		}
+	}
+}
+
+func TestHitTestBoxToBox(t *testing.T) {
+	tree, parent := newRotatedParentTree()
+	root := tree.GetBoxROOT()
+	// 绝对坐标为 x 230..250 y 50..70
+	child := NewBox(0, 0, 20, 20, "")
+	tree.AddBox(child, parent)
+	square := NewBox(0, 0, 10, 10, "")
+	tree.AddBox(square, root)
+
+	newBox := func(x, y, w, h int, angle float64) *Box {
+		box := NewBox(x, y, w, h, "")
+		box.angle = angle
+		tree.AddBox(box, root)
+		return box
+	}
+	cases := []struct {
+		name       string
+		box1, box2 *Box
+		want       bool
+	}{
+		{"相交", square, newBox(5, 5, 10, 10, 0), true},
+		{"边接触", square, newBox(10, 0, 10, 10, 0), false},
+		{"角接触", square, newBox(10, 10, 10, 10, 0), false},
+		{"45°相交", square, newBox(7, 7, 10, 10, math.Pi/4), true},
+		{"45°外框相交但分离", square, newBox(10, 10, 10, 10, math.Pi/4), false},
+		{"两个45°相交", newBox(100, 100, 10, 10, math.Pi/4), newBox(105, 105, 10, 10, math.Pi/4), true},
+		{"父控件旋转 相交", child, newBox(245, 65, 10, 10, 0), true},
+		{"父控件旋转 边接触", child, newBox(250, 50, 10, 10, 0), false},
+		{"父控件旋转 未旋转时的位置", child, newBox(100, 100, 20, 20, 0), false},
+		{"父控件旋转 45°相交", child, newBox(248, 68, 10, 10, math.Pi/4), true},
+		{"父控件旋转 45°外框相交但分离", child, newBox(251, 71, 10, 10, math.Pi/4), false},
+	}
+	for _, c := range cases {
+		if got := tree.HitTestBoxToBox(c.box1, c.box2); got != c.want {
+			t.Errorf("%s: HitTestBoxToBox = %v, want %v", c.name, got, c.want)
+		}
+		if got := tree.HitTestBoxToBox(c.box2, c.box1); got != c.want {
+			t.Errorf("%s (交换): HitTestBoxToBox = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
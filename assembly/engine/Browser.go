@@ -0,0 +1,262 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"image"
+	"math"
+	"sort"
+	"syscall/js"
+)
+
+// NewBrowserEngine 浏览器中的引擎 舞台尺寸由页面的isReady提供 渲染结果输出到页面的printer
+func NewBrowserEngine() (engine *Engine) {
+	engine = NewEngine()
+	initStage := js.NewCallback(func(args []js.Value) {
+		engine.InitStage(args[0].Int(), args[1].Int(), NewScreenOutput())
+		bindMouseEvents(engine)
+		bindKeyboardEvents(engine)
+		bindClipboardEvents(engine)
+	})
+	js.Global().Get("window").Call("isReady", initStage)
+	return engine
+}
+
+// ReleaseBrowserEngine 释放浏览器引擎的输出回调 页面关闭前调用
+func ReleaseBrowserEngine(engine *Engine) {
+	if engine.render == nil {
+		return
+	}
+	if output, ok := engine.render.output.(*ScreenOutput); ok {
+		output.Release()
+	}
+}
+
+// 接收#main-box上的系统鼠标事件 滚轮以鼠标位置为中心缩放
+// 页面通过 window.resizeStage(width, height) 通知canvas尺寸变化
+func bindMouseEvents(engine *Engine) {
+	manager := engine.mouseEvent
+	//系统鼠标事件接收
+	sysMouseHandler := js.NewCallback(func(args []js.Value) {
+		//chrome or firefox
+		x := args[0].Get("offsetX").Int()
+		y := args[0].Get("offsetY").Int()
+		if x == 0 {
+			x = args[0].Get("layerX").Int()
+		}
+		if y == 0 {
+			y = args[0].Get("layerY").Int()
+		}
+		evt := args[0]
+		manager.DispatchSystemEvent(evt.Get("type").String(), x, y, evt.Get("button").Int(),
+			evt.Get("ctrlKey").Bool(), evt.Get("shiftKey").Bool(), evt.Get("altKey").Bool(), evt.Get("metaKey").Bool())
+	})
+
+	//滚轮缩放舞台 同步阻止页面滚动
+	wheelHandler := js.NewEventCallback(js.PreventDefault, func(evt js.Value) {
+		engine.ZoomAt(evt.Get("offsetX").Int(), evt.Get("offsetY").Int(), math.Exp(-evt.Get("deltaY").Float()*0.002))
+	})
+
+	mainBox := js.Global().Get("document").Call("getElementById", "main-box")
+	mainBox.Call("addEventListener", "mousedown", sysMouseHandler)
+	mainBox.Call("addEventListener", "mouseup", sysMouseHandler)
+	mainBox.Call("addEventListener", "mousemove", sysMouseHandler)
+	mainBox.Call("addEventListener", "click", sysMouseHandler)
+	mainBox.Call("addEventListener", "dblclick", sysMouseHandler)
+	mainBox.Call("addEventListener", "wheel", wheelHandler, map[string]interface{}{"passive": false})
+
+	resizeHandler := js.NewCallback(func(args []js.Value) {
+		engine.SetScreenSize(args[0].Int(), args[1].Int())
+	})
+	js.Global().Get("window").Set("resizeStage", resizeHandler)
+
+	// defer sysMouseHandler.Release()
+}
+
+// 输入框中的事件交给浏览器 js代码片段 用于同步的监听函数
+const jsSkipEditing = `var tag = evt.target.tagName; if (tag === "INPUT" || tag === "TEXTAREA") { return; }`
+
+// 创建在事件分发时同步执行的js监听函数 body 为函数体 可以使用参数evt和params中的变量
+// js.NewCallback 的回调在事件分发结束后才执行 preventDefault 和 clipboardData 只能在这里同步处理
+func newSyncListener(body string, params map[string]interface{}) js.Value {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := make([]interface{}, 0, len(names)+1)
+	values := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		args = append(args, name)
+		values = append(values, params[name])
+	}
+	args = append(args, "return function(evt) {"+body+"};")
+	return js.Global().Get("Function").New(args...).Invoke(values...)
+}
+
+// 接收document上的系统键盘事件 输入框中的按键不处理 注册了的快捷键阻止浏览器的默认行为
+// 页面通过 window.bindShortcut(shortcut, callback) 和 window.unbindShortcut(shortcut) 注册或覆盖快捷键
+func bindKeyboardEvents(engine *Engine) {
+	keyHandler := js.NewCallback(func(args []js.Value) {
+		evt := args[0]
+		tagName := evt.Get("target").Get("tagName").String()
+		if tagName == "INPUT" || tagName == "TEXTAREA" {
+			return
+		}
+		engine.keyboard.DispatchSystemEvent(evt.Get("type").String(), evt.Get("key").String(),
+			evt.Get("ctrlKey").Bool(), evt.Get("shiftKey").Bool(), evt.Get("altKey").Bool(), evt.Get("metaKey").Bool())
+	})
+	doc := js.Global().Get("document")
+	doc.Call("addEventListener", "keydown", keyHandler)
+	doc.Call("addEventListener", "keyup", keyHandler)
+
+	//按键与formatShortcut相同的规则规范后 是注册了的快捷键时同步阻止默认行为 比如滚动和返回上一页
+	shifted := make(map[string]interface{})
+	for key, base := range shiftedKeys {
+		shifted[key] = base
+	}
+	holder := js.Global().Get("Object").New()
+	engine.keyboard.SetShortcutsHandler(func(shortcuts []string) {
+		table := js.Global().Get("Object").New()
+		for _, shortcut := range shortcuts {
+			table.Set(shortcut, true)
+		}
+		holder.Set("shortcuts", table)
+	})
+	doc.Call("addEventListener", "keydown", newSyncListener(jsSkipEditing+`
+		var key = evt.key;
+		if (key.length === 1) { key = key.toUpperCase(); }
+		if (evt.shiftKey && shiftedKeys.hasOwnProperty(key)) { key = shiftedKeys[key]; }
+		if (key === " ") { key = "Space"; }
+		var name = (evt.ctrlKey ? "Ctrl+" : "") + (evt.altKey ? "Alt+" : "") + (evt.shiftKey ? "Shift+" : "") + (evt.metaKey ? "Meta+" : "") + key;
+		if (holder.shortcuts[name]) { evt.preventDefault(); }`,
+		map[string]interface{}{"holder": holder, "shiftedKeys": shifted}))
+	//窗口失去焦点时收不到keyup
+	blurHandler := js.NewCallback(func(args []js.Value) {
+		engine.keyboard.ReleaseKeys()
+	})
+	js.Global().Get("window").Call("addEventListener", "blur", blurHandler)
+
+	bindShortcutHandler := js.NewCallback(func(args []js.Value) {
+		callback := args[1]
+		engine.BindShortcut(args[0].String(), func(evt KeyEvent) {
+			callback.Invoke(evt.Shortcut())
+		})
+	})
+	js.Global().Get("window").Set("bindShortcut", bindShortcutHandler)
+	unbindShortcutHandler := js.NewCallback(func(args []js.Value) {
+		engine.UnbindShortcut(args[0].String())
+	})
+	js.Global().Get("window").Set("unbindShortcut", unbindShortcutHandler)
+}
+
+// 通过document上的copy cut paste事件与系统剪贴板交换控件数据 可以在两个编辑器页面之间复制
+// 复制剪切粘贴的快捷键交给浏览器 浏览器再发出这些事件 按住Shift粘贴时放到鼠标位置
+// 事件的默认行为和clipboardData只在分发时有效 由同步的js监听函数处理 复制的数据通过navigator.clipboard写入
+func bindClipboardEvents(engine *Engine) {
+	for _, key := range []string{"C", "X", "V", "Shift+V"} {
+		engine.UnbindShortcut("Ctrl+" + key)
+		engine.UnbindShortcut("Meta+" + key)
+	}
+	holder := js.Global().Get("Object").New()
+	holder.Set("hasSelection", false)
+	engine.AddSelectionHandler(func(selection []*Box) {
+		holder.Set("hasSelection", len(selection) > 0)
+	})
+
+	copyHandler := js.NewCallback(func(args []js.Value) {
+		var data []byte
+		if args[0].String() == "cut" {
+			data = engine.Cut()
+		} else {
+			data = engine.Copy()
+		}
+		clipboard := js.Global().Get("navigator").Get("clipboard")
+		if data == nil || clipboard.Type() == js.TypeUndefined {
+			return
+		}
+		clipboard.Call("writeText", string(data))
+	})
+	pasteHandler := js.NewCallback(func(args []js.Value) {
+		if err := engine.SetClipboardData([]byte(args[0].String())); err != nil {
+			return
+		}
+		if engine.keyboard.IsPressed("Shift") {
+			x, y := engine.mouseEvent.GetMousePosition()
+			engine.PasteAt(x, y)
+		} else {
+			engine.Paste()
+		}
+	})
+
+	//选中了页面上的文字时按浏览器默认的方式复制
+	doc := js.Global().Get("document")
+	copyListener := newSyncListener(jsSkipEditing+`
+		if (!holder.hasSelection || !window.getSelection().isCollapsed) { return; }
+		evt.preventDefault();
+		copyHandler(evt.type);`,
+		map[string]interface{}{"holder": holder, "copyHandler": copyHandler})
+	doc.Call("addEventListener", "copy", copyListener)
+	doc.Call("addEventListener", "cut", copyListener)
+	doc.Call("addEventListener", "paste", newSyncListener(jsSkipEditing+`
+		evt.preventDefault();
+		pasteHandler(evt.clipboardData.getData("text/plain"));`,
+		map[string]interface{}{"pasteHandler": pasteHandler}))
+}
+
+// ScreenOutput 输出到屏幕 通过页面的printer绘制到canvas
+// 同一帧内的多次输出在下一次requestAnimationFrame时按顺序绘制 回调只创建一次
+type ScreenOutput struct {
+	frames    []screenFrame
+	scheduled bool
+	print     js.Callback
+}
+
+// 等待绘制的一块区域
+type screenFrame struct {
+	rgba *image.RGBA
+	x, y int
+}
+
+// NewScreenOutput 构造函数
+func NewScreenOutput() (output *ScreenOutput) {
+	output = &ScreenOutput{}
+	output.frames = make([]screenFrame, 0)
+	output.print = js.NewCallback(func(args []js.Value) {
+		output.flush()
+	})
+	return output
+}
+
+// Output 绘制到屏幕
+func (t *ScreenOutput) Output(rgba *image.RGBA, x, y int) {
+	bounds := rgba.Bounds()
+	if bounds.Dx() <= 0 || bounds.Dy() <= 0 {
+		return
+	}
+	t.frames = append(t.frames, screenFrame{rgba, x, y})
+	if !t.scheduled {
+		t.scheduled = true
+		js.Global().Call("requestAnimationFrame", t.print)
+	}
+}
+
+// Release 释放回调
+func (t *ScreenOutput) Release() {
+	t.print.Release()
+	t.frames = nil
+}
+
+// 把等待的区域依次交给printer
+func (t *ScreenOutput) flush() {
+	printer := js.Global().Get("window").Get("printer")
+	for _, frame := range t.frames {
+		bounds := frame.rgba.Bounds()
+		pix := js.TypedArrayOf(frame.rgba.Pix)
+		printer.Invoke(pix, frame.x, frame.y, bounds.Dx(), bounds.Dy())
+		pix.Release()
+	}
+	t.frames = t.frames[:0]
+	t.scheduled = false
+}
@@ -0,0 +1,84 @@
+package main
+
+import "math"
+
+const (
+	// MINZOOM 最小缩放
+	MINZOOM = 0.1
+	// MAXZOOM 最大缩放
+	MAXZOOM = 20.0
+	// FITPADDING 缩放到适应窗口时四周留白的屏幕像素
+	FITPADDING = 20
+)
+
+// Camera 视图相机 x y 为屏幕左上角对应的舞台坐标 width height 为屏幕尺寸
+// 屏幕坐标 = (舞台坐标 - 相机坐标) * zoom
+type Camera struct {
+	x      float64
+	y      float64
+	zoom   float64
+	width  int
+	height int
+}
+
+// NewCamera 构造函数 width height 为屏幕尺寸
+func NewCamera(width, height int) *Camera {
+	return &Camera{0, 0, 1, width, height}
+}
+
+// GetZoom 当前缩放
+func (t *Camera) GetZoom() float64 {
+	return t.zoom
+}
+
+// SetSize 屏幕尺寸变化
+func (t *Camera) SetSize(width, height int) {
+	t.width = width
+	t.height = height
+}
+
+// ToWorld 屏幕坐标转为舞台坐标
+func (t *Camera) ToWorld(sx, sy int) (int, int) {
+	return int(math.Floor(float64(sx)/t.zoom + t.x)), int(math.Floor(float64(sy)/t.zoom + t.y))
+}
+
+// ToScreen 舞台坐标转为屏幕坐标
+func (t *Camera) ToScreen(wx, wy float64) (float64, float64) {
+	return (wx - t.x) * t.zoom, (wy - t.y) * t.zoom
+}
+
+// VisibleRect 屏幕上可见的舞台区域
+func (t *Camera) VisibleRect() *Rect {
+	x := int(math.Floor(t.x))
+	y := int(math.Floor(t.y))
+	width := int(math.Ceil(t.x+float64(t.width)/t.zoom)) - x
+	height := int(math.Ceil(t.y+float64(t.height)/t.zoom)) - y
+	return &Rect{x, y, width, height}
+}
+
+// PanBy 平移 dx dy 为屏幕上的位移
+func (t *Camera) PanBy(dx, dy int) {
+	t.x -= float64(dx) / t.zoom
+	t.y -= float64(dy) / t.zoom
+}
+
+// ZoomAt 以屏幕上的一个点为中心缩放 缩放后该点对应的舞台坐标不变
+func (t *Camera) ZoomAt(sx, sy int, zoom float64) {
+	zoom = math.Min(math.Max(zoom, MINZOOM), MAXZOOM)
+	t.x += float64(sx)/t.zoom - float64(sx)/zoom
+	t.y += float64(sy)/t.zoom - float64(sy)/zoom
+	t.zoom = zoom
+}
+
+// FitBounds 缩放并平移 使舞台上的一块区域居中完整显示 padding 为四周留白的屏幕像素
+func (t *Camera) FitBounds(bounds Bounds, padding int) {
+	if bounds.width <= 0 || bounds.height <= 0 {
+		return
+	}
+	sw := math.Max(float64(t.width-2*padding), 1)
+	sh := math.Max(float64(t.height-2*padding), 1)
+	zoom := math.Min(sw/float64(bounds.width), sh/float64(bounds.height))
+	t.zoom = math.Min(math.Max(zoom, MINZOOM), MAXZOOM)
+	t.x = float64(bounds.x) + float64(bounds.width)/2 - float64(t.width)/2/t.zoom
+	t.y = float64(bounds.y) + float64(bounds.height)/2 - float64(t.height)/2/t.zoom
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CLIPBOARDTYPE 剪贴板文本的类型标记 用于识别系统剪贴板中的控件数据
+const CLIPBOARDTYPE = "assembly/boxes"
+
+// PASTEOFFSET 连续粘贴或创建副本时每次的偏移 舞台坐标
+const PASTEOFFSET = 10
+
+// ClipboardDocument 剪贴板数据 顶层控件的坐标和角度是绝对的 子控件相对父控件
+// styles 为控件用到的类的规则 粘贴到没有这些类的编辑器中时添加
+type ClipboardDocument struct {
+	Type    string                    `json:"type"`
+	Version int                       `json:"version"`
+	Styles  map[string]*StyleDocument `json:"styles,omitempty"`
+	Boxes   []*BoxDocument            `json:"boxes"`
+}
+
+// NewClipboardDocument 从一组控件（连同子控件）生成剪贴板数据 boxes 中不能同时包含父控件和它的子控件
+func NewClipboardDocument(boxes []*Box, styleSheet *StyleSheetManager) (doc *ClipboardDocument) {
+	doc = &ClipboardDocument{}
+	doc.Type = CLIPBOARDTYPE
+	doc.Version = DOCUMENTVERSION
+	doc.Boxes = newBoxDocuments(boxes)
+	//顶层控件改为绝对坐标和角度 没有父节点的变换等同于绝对坐标系
+	root := NewBox(0, 0, 0, 0, "")
+	classes := make(map[string]bool)
+	for idx, box := range boxes {
+		geometry := reparentGeometry(box, root)
+		doc.Boxes[idx].X = geometry.x
+		doc.Boxes[idx].Y = geometry.y
+		doc.Boxes[idx].Angle = geometry.angle
+		for _, b := range append([]*Box{box}, getDescendants(box)...) {
+			for _, class := range b.GetClasses() {
+				classes[class] = true
+			}
+		}
+	}
+	doc.Styles = make(map[string]*StyleDocument)
+	for selector, rule := range styleSheet.GetRules() {
+		if classes[strings.SplitN(selector, ":", 2)[0]] {
+			doc.Styles[selector] = newStyleDocument(rule)
+		}
+	}
+	return doc
+}
+
+// DecodeClipboardDocument 解析剪贴板文本 不是控件数据或版本比当前新时返回错误
+func DecodeClipboardDocument(data []byte) (*ClipboardDocument, error) {
+	doc := &ClipboardDocument{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("clipboard: %v", err)
+	}
+	if doc.Type != CLIPBOARDTYPE {
+		return nil, errors.New("clipboard: not a box payload")
+	}
+	if doc.Version > DOCUMENTVERSION {
+		return nil, fmt.Errorf("clipboard: version %d is newer than %d", doc.Version, DOCUMENTVERSION)
+	}
+	return doc, nil
+}
+
+// Encode 生成json文本
+func (t *ClipboardDocument) Encode() ([]byte, error) {
+	return json.Marshal(t)
+}
+
+// Build 创建不在boxtree中的控件（连同子控件） 每次调用都创建新的控件 使用新的id 坐标和角度是绝对的
+func (t *ClipboardDocument) Build() ([]*Box, error) {
+	boxes := make([]*Box, 0, len(t.Boxes))
+	for _, bd := range t.Boxes {
+		box, err := newSubtreeFromDocument(bd)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range append([]*Box{box}, getDescendants(box)...) {
+			b.id = newBoxID()
+		}
+		boxes = append(boxes, box)
+	}
+	return boxes, nil
+}
+
+// AddMissingStyles 把样式表中没有的规则添加到样式表 已有的同名规则不覆盖
+func (t *ClipboardDocument) AddMissingStyles(styleSheet *StyleSheetManager) error {
+	rules := styleSheet.GetRules()
+	for selector, sd := range t.Styles {
+		if _, ok := rules[selector]; ok {
+			continue
+		}
+		rule, err := sd.toStyleRule(selector)
+		if err != nil {
+			return fmt.Errorf("clipboard: style %q: %v", selector, err)
+		}
+		styleSheet.AddRule(selector, rule)
+	}
+	return nil
+}
+
+// Clipboard 剪贴板 保存最近一次复制的控件数据 与系统剪贴板交换序列化的文本
+type Clipboard struct {
+	data       []byte
+	doc        *ClipboardDocument
+	pasteCount int //同一份数据粘贴的次数 每次粘贴多偏移一次
+}
+
+// NewClipboard 构造函数
+func NewClipboard() (clipboard *Clipboard) {
+	clipboard = &Clipboard{}
+	return clipboard
+}
+
+// IsEmpty 是否没有数据
+func (t *Clipboard) IsEmpty() bool {
+	return t.doc == nil
+}
+
+// Data 获取序列化的文本
+func (t *Clipboard) Data() []byte {
+	return t.data
+}
+
+// SetDocument 复制新的数据 cut 为true时原来的控件已经删除 第一次粘贴不偏移
+func (t *Clipboard) SetDocument(doc *ClipboardDocument, cut bool) error {
+	data, err := doc.Encode()
+	if err != nil {
+		return err
+	}
+	t.data = data
+	t.doc = doc
+	t.pasteCount = 0
+	if cut {
+		t.pasteCount = -1
+	}
+	return nil
+}
+
+// SetData 设置系统剪贴板中的文本 与当前数据相同时保留粘贴次数
+func (t *Clipboard) SetData(data []byte) error {
+	if t.doc != nil && bytes.Equal(data, t.data) {
+		return nil
+	}
+	doc, err := DecodeClipboardDocument(data)
+	if err != nil {
+		return err
+	}
+	t.data = append([]byte{}, data...)
+	t.doc = doc
+	t.pasteCount = 0
+	return nil
+}
+
+// nextPaste 返回要粘贴的数据和这次粘贴的偏移 粘贴成功后调用pasted
+func (t *Clipboard) nextPaste() (*ClipboardDocument, int) {
+	return t.doc, (t.pasteCount + 1) * PASTEOFFSET
+}
+
+// pasted 粘贴成功 下一次粘贴多偏移一次
+func (t *Clipboard) pasted() {
+	t.pasteCount++
+}
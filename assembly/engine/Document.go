@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// DOCUMENTVERSION 当前文档格式版本 格式变化时加一 并注册上一版本的升级函数
+const DOCUMENTVERSION = 2
+
+// Document 地图文档 用于保存和加载boxtree
+type Document struct {
+	Version int                       `json:"version"`
+	Width   int                       `json:"width"`
+	Height  int                       `json:"height"`
+	Styles  map[string]*StyleDocument `json:"styles"`
+	Themes  map[string]ThemeDocument  `json:"themes,omitempty"`
+	Theme   string                    `json:"theme,omitempty"` //当前主题
+	Boxes   []*BoxDocument            `json:"boxes"`           //根节点的子节点 按照z-index排序
+}
+
+// ThemeDocument 主题数据 颜色名到 #rrggbbaa
+type ThemeDocument map[string]string
+
+// BoxDocument 控件数据 坐标相对父节点
+type BoxDocument struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name,omitempty"`
+	X          int            `json:"x"`
+	Y          int            `json:"y"`
+	Width      int            `json:"width"`
+	Height     int            `json:"height"`
+	Angle      float64        `json:"angle"`
+	StyleClass string         `json:"styleClass"`
+	Style      *StyleDocument `json:"style,omitempty"` //内联样式
+	IsUsed     bool           `json:"isUsed"`
+	Children   []*BoxDocument `json:"children,omitempty"`
+}
+
+// StyleDocument 样式数据 颜色为 #rrggbbaa 没有opacity时不透明
+// inherit 中列出的属性没有声明 从父控件继承 旧文档没有inherit 所有属性都是声明的
+type StyleDocument struct {
+	BackgroundColor string    `json:"backgroundColor,omitempty"`
+	BgTransparent   bool      `json:"bgTransparent"`
+	BorderColor     string    `json:"borderColor,omitempty"`
+	BorderWeight    int       `json:"borderWeight"`
+	Opacity         *float64  `json:"opacity,omitempty"`
+	Dash            []float64 `json:"dash,omitempty"`
+	Radius          int       `json:"radius,omitempty"`
+	Inherit         []string  `json:"inherit,omitempty"`
+}
+
+// DocumentMigration 把文档从上一版本升级到下一版本 doc为json解析出的原始数据
+type DocumentMigration func(doc map[string]interface{}) error
+
+// 升级函数 key为升级前的版本号
+var documentMigrations = make(map[int]DocumentMigration)
+
+// RegisterDocumentMigration 注册从fromVersion升级到fromVersion+1的函数
+func RegisterDocumentMigration(fromVersion int, migration DocumentMigration) {
+	documentMigrations[fromVersion] = migration
+}
+
+func init() {
+	// 版本1的控件没有id
+	RegisterDocumentMigration(1, func(doc map[string]interface{}) error {
+		assignBoxDocumentIDs(doc["boxes"])
+		return nil
+	})
+}
+
+// 为原始数据中的控件（连同子控件）生成id
+func assignBoxDocumentIDs(list interface{}) {
+	boxes, _ := list.([]interface{})
+	for _, v := range boxes {
+		if bd, ok := v.(map[string]interface{}); ok {
+			bd["id"] = newBoxID()
+			assignBoxDocumentIDs(bd["children"])
+		}
+	}
+}
+
+// NewDocument 从boxtree和样式生成文档
+func NewDocument(tree *BoxTree, styleSheet *StyleSheetManager) (doc *Document) {
+	root := tree.GetBoxROOT()
+	doc = &Document{}
+	doc.Version = DOCUMENTVERSION
+	doc.Width = root.width
+	doc.Height = root.height
+	doc.Styles = make(map[string]*StyleDocument)
+	for name, rule := range styleSheet.GetRules() {
+		doc.Styles[name] = newStyleDocument(rule)
+	}
+	doc.Themes = make(map[string]ThemeDocument)
+	for _, name := range styleSheet.GetThemeNames() {
+		doc.Themes[name] = newThemeDocument(styleSheet.themes[name])
+	}
+	doc.Theme = styleSheet.GetTheme()
+	doc.Boxes = newBoxDocuments(root.children)
+	return doc
+}
+
+// DecodeDocument 解析文档 旧版本的文档会依次升级到当前版本
+func DecodeDocument(data []byte) (*Document, error) {
+	raw := make(map[string]interface{})
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	version, ok := raw["version"].(float64)
+	if !ok {
+		return nil, errors.New("document: missing version")
+	}
+	v := int(version)
+	if v > DOCUMENTVERSION {
+		return nil, fmt.Errorf("document: version %d is newer than %d", v, DOCUMENTVERSION)
+	}
+	if v < DOCUMENTVERSION {
+		for ; v < DOCUMENTVERSION; v++ {
+			migration, ok := documentMigrations[v]
+			if !ok {
+				return nil, fmt.Errorf("document: no migration from version %d", v)
+			}
+			if err := migration(raw); err != nil {
+				return nil, err
+			}
+		}
+		raw["version"] = DOCUMENTVERSION
+		var err error
+		if data, err = json.Marshal(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	doc := &Document{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Encode 生成json
+func (t *Document) Encode() ([]byte, error) {
+	return json.Marshal(t)
+}
+
+// Build 把文档中的样式和控件添加到样式管理器和boxtree中 控件挂到根节点下
+// 文档有错误时返回错误 样式管理器和boxtree不变
+func (t *Document) Build(tree *BoxTree, styleSheet *StyleSheetManager) error {
+	content, err := t.convert(styleSheet)
+	if err != nil {
+		return err
+	}
+	content.apply(tree, styleSheet)
+	return nil
+}
+
+// documentContent 从文档转换出的样式 主题和控件 还没有加入样式管理器和boxtree
+type documentContent struct {
+	rules  map[string]*StyleRule
+	themes []*Theme
+	theme  string
+	boxes  []*Box //根节点的子树
+}
+
+// 转换文档 检查所有错误 不修改样式管理器
+func (t *Document) convert(styleSheet *StyleSheetManager) (*documentContent, error) {
+	content := &documentContent{}
+	content.rules = make(map[string]*StyleRule)
+	for name, sd := range t.Styles {
+		rule, err := sd.toStyleRule(name)
+		if err != nil {
+			return nil, fmt.Errorf("document: style %q: %v", name, err)
+		}
+		content.rules[name] = rule
+	}
+	for name, td := range t.Themes {
+		theme, err := td.toTheme(name)
+		if err != nil {
+			return nil, fmt.Errorf("document: theme %q: %v", name, err)
+		}
+		content.themes = append(content.themes, theme)
+	}
+	if t.Theme != "" {
+		if _, ok := t.Themes[t.Theme]; !ok && styleSheet.themes[t.Theme] == nil {
+			return nil, fmt.Errorf("document: stylesheet: unknown theme %q", t.Theme)
+		}
+		content.theme = t.Theme
+	}
+	ids := make(map[string]bool)
+	for _, bd := range t.Boxes {
+		box, err := newSubtreeFromDocument(bd)
+		if err != nil {
+			return nil, err
+		}
+		//文档中的id被外部引用 重复时报错 不能像新建的控件那样重新生成
+		for _, b := range append([]*Box{box}, getDescendants(box)...) {
+			if b.id == ROOTID || ids[b.id] {
+				return nil, fmt.Errorf("document: duplicate box id %q", b.id)
+			}
+			ids[b.id] = true
+		}
+		content.boxes = append(content.boxes, box)
+	}
+	return content, nil
+}
+
+// 把转换好的内容加入样式管理器和boxtree
+func (t *documentContent) apply(tree *BoxTree, styleSheet *StyleSheetManager) {
+	for name, rule := range t.rules {
+		styleSheet.AddRule(name, rule)
+	}
+	for _, theme := range t.themes {
+		styleSheet.AddTheme(theme)
+	}
+	if t.theme != "" {
+		styleSheet.SetTheme(t.theme)
+	}
+	for _, box := range t.boxes {
+		tree.InsertBox(box, tree.GetBoxROOT(), -1)
+	}
+}
+
+// 从控件数据创建控件 不包含子控件
+func newBoxFromDocument(bd *BoxDocument) (*Box, error) {
+	box := NewBox(bd.X, bd.Y, bd.Width, bd.Height, bd.StyleClass)
+	if bd.ID != "" {
+		box.id = bd.ID
+	}
+	box.name = bd.Name
+	box.angle = bd.Angle
+	box.isUsed = bd.IsUsed
+	if bd.Style != nil {
+		rule, err := bd.Style.toStyleRule("")
+		if err != nil {
+			return nil, fmt.Errorf("document: inline style: %v", err)
+		}
+		box.inlineStyle = rule
+	}
+	return box, nil
+}
+
+// 从控件数据创建一棵不在boxtree中的子树 挂到boxtree时由InsertBox加入子控件
+func newSubtreeFromDocument(bd *BoxDocument) (*Box, error) {
+	box, err := newBoxFromDocument(bd)
+	if err != nil {
+		return nil, err
+	}
+	for _, cd := range bd.Children {
+		child, err := newSubtreeFromDocument(cd)
+		if err != nil {
+			return nil, err
+		}
+		child.parent = box
+		child.zIndex = len(box.children)
+		box.children = append(box.children, child)
+	}
+	return box, nil
+}
+
+func newBoxDocuments(boxes []*Box) []*BoxDocument {
+	list := make([]*BoxDocument, 0, len(boxes))
+	for _, box := range boxes {
+		bd := &BoxDocument{}
+		bd.ID = box.id
+		bd.Name = box.name
+		bd.X = box.x
+		bd.Y = box.y
+		bd.Width = box.width
+		bd.Height = box.height
+		bd.Angle = box.angle
+		bd.StyleClass = box.styleClass
+		if box.inlineStyle != nil {
+			bd.Style = newStyleDocument(box.inlineStyle)
+		}
+		bd.IsUsed = box.isUsed
+		if len(box.children) > 0 {
+			bd.Children = newBoxDocuments(box.children)
+		}
+		list = append(list, bd)
+	}
+	return list
+}
+
+func newStyleDocument(rule *StyleRule) *StyleDocument {
+	style := &rule.style
+	sd := &StyleDocument{}
+	sd.BackgroundColor = formatColor(style.backgroundColor)
+	sd.BgTransparent = style.bgTransparent
+	sd.BorderColor = formatColor(style.borderColor)
+	sd.BorderWeight = style.borderWeight
+	if style.opacity < 1 {
+		opacity := style.opacity
+		sd.Opacity = &opacity
+	}
+	sd.Dash = style.dash
+	sd.Radius = style.radius
+	for _, property := range styleProperties {
+		if rule.properties&property == 0 {
+			sd.Inherit = append(sd.Inherit, stylePropertyNames[property])
+		}
+	}
+	return sd
+}
+
+func (t *StyleDocument) toStyleRule(selector string) (*StyleRule, error) {
+	style := NewStyle(nil, false, nil, 0)
+	var err error
+	if style.backgroundColor, err = parseHexColor(t.BackgroundColor); err != nil {
+		return nil, err
+	}
+	if style.borderColor, err = parseHexColor(t.BorderColor); err != nil {
+		return nil, err
+	}
+	style.bgTransparent = t.BgTransparent
+	style.borderWeight = t.BorderWeight
+	if t.Opacity != nil {
+		if *t.Opacity < 0 || *t.Opacity > 1 {
+			return nil, fmt.Errorf("invalid opacity %v", *t.Opacity)
+		}
+		style.opacity = *t.Opacity
+	}
+	for _, v := range t.Dash {
+		if v < 0 {
+			return nil, fmt.Errorf("invalid dash %v", t.Dash)
+		}
+	}
+	style.dash = t.Dash
+	style.radius = t.Radius
+	properties := ALLPROPERTIES
+	for _, name := range t.Inherit {
+		found := false
+		for property, n := range stylePropertyNames {
+			if n == name {
+				properties &^= property
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown property %q", name)
+		}
+	}
+	return NewStyleRule(selector, style, properties), nil
+}
+
+func newThemeDocument(theme *Theme) ThemeDocument {
+	td := make(ThemeDocument)
+	for token, c := range theme.tokens {
+		td[token] = formatColor(c)
+	}
+	return td
+}
+
+func (t ThemeDocument) toTheme(name string) (*Theme, error) {
+	theme := NewTheme(name)
+	for token, value := range t {
+		c, err := parseHexColor(value)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := c.(ThemeColor); ok || c == nil {
+			return nil, fmt.Errorf("invalid color %q for %q", value, token)
+		}
+		theme.SetToken(token, c)
+	}
+	return theme, nil
+}
+
+// 颜色转为 #rrggbbaa 主题颜色转为 var(--token) nil转为空字符串
+func formatColor(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+	if token, ok := c.(ThemeColor); ok {
+		return "var(--" + string(token) + ")"
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
+
+// 解析 #rrggbb 或 #rrggbbaa 以及 var(--token) 空字符串解析为nil
+func parseHexColor(value string) (color.Color, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(value, "var(") {
+		return parseColor(value)
+	}
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return nil, fmt.Errorf("invalid color %q", value)
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %q", value)
+	}
+	return color.NRGBA{uint8(n >> 24), uint8(n >> 16), uint8(n >> 8), uint8(n)}, nil
+}
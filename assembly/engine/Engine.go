@@ -1,43 +1,1283 @@
 package main
 
 import (
-	"syscall/js"
-	"time"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 )
 
+// SelectionHandler 选中变化回调 selection为空表示取消选中
+type SelectionHandler func(selection []*Box)
+
+// BoxEventHandler 控件行为回调 比如ROTATESTART ROTATEEND
+type BoxEventHandler func(box *Box, event BoxEvent)
+
+// EditorConfig 编辑配置
+type EditorConfig struct {
+	minWidth        int                 //拉伸时的最小宽度
+	minHeight       int                 //拉伸时的最小高度
+	keepAspectRatio bool                //拖拽四角时保持宽高比
+	rotateSnap      float64             //旋转吸附的角度增量 弧度 0表示不吸附
+	containRules    map[string][]string //容器类可以包含的控件类
+	snapGrid        int                 //网格吸附的间距 0表示不吸附到网格
+	snapToBoxes     bool                //吸附到其他控件的边和中心
+	snapTolerance   int                 //吸附距离 屏幕像素
+}
+
 // Engine 引擎定义
 type Engine struct {
-	boxTree    *BoxTree
-	mouseEvent *MouseEventManager
-	styleSheet *StyleSheetManager
-	render     *RenderEngine
+	boxTree           *BoxTree
+	mouseEvent        *MouseEventManager
+	keyboard          *KeyboardEventManager
+	styleSheet        *StyleSheetManager
+	render            *RenderEngine
+	config            *EditorConfig
+	history           *History
+	stateMachines     map[*Box]*BoxStateMachine
+	selection         []*Box //选中的控件 按选中的先后顺序
+	marquee           *Marquee
+	snapper           *Snapper
+	clipboard         *Clipboard
+	enteredGroup      *Box           //进入的组合 可以单独编辑其中的成员 nil表示没有进入组合
+	groupScope        *BoxBorderView //进入组合时在交互层显示组合的范围
+	selectionHandlers []SelectionHandler
+	boxEventHandlers  []BoxEventHandler
 }
 
-// NewEngine 构造函数
+// NewEngine 构造函数 舞台在InitStage中创建 样式表在舞台创建之前就可以使用
 func NewEngine() (engine *Engine) {
 	engine = &Engine{}
-	initStage := js.NewCallback(func(args []js.Value) {
-		engine.boxTree = NewBoxTree(args[0].Int(), args[1].Int())
-		engine.mouseEvent = NewMouseEventManager(engine.boxTree)
-		engine.styleSheet = NewStyleSheetManager()
-		engine.render = NewRenderEngine(engine.boxTree, engine.styleSheet)
-	})
-	js.Global().Get("window").Call("isReady", initStage)
+	engine.styleSheet = NewStyleSheetManager()
+	engine.config = &EditorConfig{10, 10, false, 0, map[string][]string{"zone": {"box", "wall", "zone"}}, 0, true, SNAPTOLERANCE}
+	engine.history = NewHistory()
+	engine.clipboard = NewClipboard()
+	engine.keyboard = NewKeyboardEventManager()
+	engine.stateMachines = make(map[*Box]*BoxStateMachine)
+	engine.selection = make([]*Box, 0)
+	engine.selectionHandlers = make([]SelectionHandler, 0)
+	engine.boxEventHandlers = make([]BoxEventHandler, 0)
+	engine.bindDefaultShortcuts()
 	return engine
 }
 
-// CreateNewBox 创建一个新的控件
-func (t *Engine) CreateNewBox(x, y, width, height int, angle float64, styleClass string) {
+// InitStage 创建舞台 output 为渲染结果的输出目标
+func (t *Engine) InitStage(width, height int, output RenderOutput) {
+	t.boxTree = NewBoxTree(width, height)
+	t.render = NewRenderEngine(t.boxTree, t.styleSheet, output)
+	t.mouseEvent = NewMouseEventManager(t.boxTree, t.render.GetCamera())
+	t.mouseEvent.SetPanController(t)
+	t.marquee = NewMarquee(t)
+	t.snapper = NewSnapper(t)
+
+	// 点击空白舞台 取消选中并退出组合
+	root := t.boxTree.GetBoxROOT()
+	t.mouseEvent.AddEventListener(root, CLICK, func(evt MouseEvent) {
+		if t.mouseEvent.IsHovering(root) && !evt.IsMultiSelect() {
+			t.setEnteredGroup(nil)
+			t.SelectBox(nil)
+		}
+	})
 
-	styleName := time.Now().String()
-	style := t.styleSheet.GetRandStyle()
-	t.styleSheet.AddStyle(styleName, style)
+	// 在空白舞台上拖拽 框选 默认选中与选框相交的控件 按住Alt只选中完全在选框内的控件
+	t.mouseEvent.AddEventListener(root, DRAGSTART, func(evt MouseEvent) {
+		t.marquee.Start(evt.mouseX, evt.mouseY)
+	})
+	t.mouseEvent.AddEventListener(root, DRAG, func(evt MouseEvent) {
+		t.marquee.Update(evt.mouseX, evt.mouseY)
+	})
+	t.mouseEvent.AddEventListener(root, DRAGEND, func(evt MouseEvent) {
+		boxes := t.marquee.End(t.marqueeContainer(), evt.altKey)
+		if evt.IsMultiSelect() {
+			boxes = append(t.GetSelection(), boxes...)
+		}
+		t.SetSelection(boxes)
+	})
+}
 
-	box := NewBox(x, y, width, height, styleName)
+// CreateNewBox 创建一个新的控件 styleClass 为样式表中的类名
+func (t *Engine) CreateNewBox(x, y, width, height int, angle float64, styleClass string) {
+	box := NewBox(x, y, width, height, styleClass)
 	box.angle = angle
-	t.boxTree.AddBox(box, t.boxTree.GetBoxROOT())
+	t.history.Execute(NewCreateBoxCommand(t, box, t.boxTree.GetBoxROOT(), -1))
+}
+
+// DeleteBox 删除控件（连同子控件）
+func (t *Engine) DeleteBox(box *Box) {
+	if box == nil || box.parent == nil {
+		return
+	}
+	t.history.Execute(NewDeleteBoxCommand(t, box))
+}
+
+// DeleteSelection 删除所有选中的控件 一步历史
+func (t *Engine) DeleteSelection() {
+	boxes := t.selectionRoots()
+	if len(boxes) == 0 {
+		return
+	}
+	//从z-index大的开始删除 撤销时从小到大恢复 兄弟节点的位置不会错乱
+	cmds := make([]Command, 0, len(boxes))
+	for idx := len(boxes) - 1; idx >= 0; idx-- {
+		cmds = append(cmds, NewDeleteBoxCommand(t, boxes[idx]))
+	}
+	t.history.Execute(NewBatchCommand(cmds...))
+}
+
+// MoveSelection 平移所有选中的控件 一步历史
+func (t *Engine) MoveSelection(dx, dy int) {
+	boxes := t.selectionRoots()
+	if len(boxes) == 0 || (dx == 0 && dy == 0) {
+		return
+	}
+	origins := make([]boxGeometry, 0, len(boxes))
+	for _, box := range boxes {
+		origins = append(origins, getBoxGeometry(box))
+	}
+	t.history.Execute(t.newMoveBoxesCommand(boxes, origins, dx, dy))
+}
+
+// SetSelectionStyle 修改所有选中控件的样式 一步历史
+func (t *Engine) SetSelectionStyle(styleClass string) {
+	cmds := make([]Command, 0, len(t.selection))
+	for _, box := range t.selection {
+		if box.styleClass != styleClass {
+			cmds = append(cmds, NewStyleBoxCommand(t, box, styleClass))
+		}
+	}
+	if len(cmds) > 0 {
+		t.history.Execute(NewBatchCommand(cmds...))
+	}
+}
+
+// BindShortcut 注册或覆盖快捷键 比如 Ctrl+Z Shift+ArrowLeft
+func (t *Engine) BindShortcut(shortcut string, handler KeyHandler) {
+	t.keyboard.BindShortcut(shortcut, handler)
+}
+
+// UnbindShortcut 取消快捷键
+func (t *Engine) UnbindShortcut(shortcut string) {
+	t.keyboard.UnbindShortcut(shortcut)
+}
+
+// 默认快捷键 Meta 对应mac上的Command
+func (t *Engine) bindDefaultShortcuts() {
+	undo := func(evt KeyEvent) {
+		t.Undo()
+	}
+	redo := func(evt KeyEvent) {
+		t.Redo()
+	}
+	t.BindShortcut("Ctrl+Z", undo)
+	t.BindShortcut("Meta+Z", undo)
+	t.BindShortcut("Ctrl+Y", redo)
+	t.BindShortcut("Ctrl+Shift+Z", redo)
+	t.BindShortcut("Meta+Shift+Z", redo)
 
-	t.render.PaintBox(box, 1)
+	remove := func(evt KeyEvent) {
+		t.DeleteSelection()
+	}
+	t.BindShortcut("Delete", remove)
+	t.BindShortcut("Backspace", remove)
+
+	//进入组合时退出组合 否则取消选中
+	t.BindShortcut("Escape", func(evt KeyEvent) {
+		if t.enteredGroup != nil {
+			t.ExitGroup()
+			return
+		}
+		t.SelectBox(nil)
+	})
+
+	group := func(evt KeyEvent) {
+		t.GroupSelection()
+	}
+	ungroup := func(evt KeyEvent) {
+		t.UngroupSelection()
+	}
+	//剪贴板 Ctrl+Shift+V 粘贴到鼠标位置
+	clipboardShortcuts := map[string]KeyHandler{
+		"C": func(evt KeyEvent) {
+			t.Copy()
+		},
+		"X": func(evt KeyEvent) {
+			t.Cut()
+		},
+		"V": func(evt KeyEvent) {
+			t.Paste()
+		},
+		"Shift+V": func(evt KeyEvent) {
+			x, y := t.mouseEvent.GetMousePosition()
+			t.PasteAt(x, y)
+		},
+		"D": func(evt KeyEvent) {
+			t.Duplicate()
+		},
+	}
+	for key, handler := range clipboardShortcuts {
+		t.BindShortcut("Ctrl+"+key, handler)
+		t.BindShortcut("Meta+"+key, handler)
+	}
+
+	t.BindShortcut("Ctrl+G", group)
+	t.BindShortcut("Meta+G", group)
+	t.BindShortcut("Ctrl+Shift+G", ungroup)
+	t.BindShortcut("Meta+Shift+G", ungroup)
+
+	t.BindShortcut("Shift+1", func(evt KeyEvent) {
+		t.ZoomToFit()
+	})
+	t.BindShortcut("Shift+2", func(evt KeyEvent) {
+		t.ZoomToSelection()
+	})
+
+	//层级 Ctrl+] 上移一层 Ctrl+[ 下移一层 加Shift置于顶层或底层
+	zorders := map[string]ZOrderOperation{"]": BRINGFORWARD, "[": SENDBACKWARD, "Shift+]": BRINGTOFRONT, "Shift+[": SENDTOBACK}
+	for key, op := range zorders {
+		op := op
+		reorder := func(evt KeyEvent) {
+			t.ReorderSelection(op)
+		}
+		t.BindShortcut("Ctrl+"+key, reorder)
+		t.BindShortcut("Meta+"+key, reorder)
+	}
+
+	//方向键移动1像素 按住Shift移动10像素
+	nudges := map[string][2]int{"ArrowLeft": {-1, 0}, "ArrowRight": {1, 0}, "ArrowUp": {0, -1}, "ArrowDown": {0, 1}}
+	for key, delta := range nudges {
+		dx, dy := delta[0], delta[1]
+		t.BindShortcut(key, func(evt KeyEvent) {
+			t.MoveSelection(dx, dy)
+		})
+		t.BindShortcut("Shift+"+key, func(evt KeyEvent) {
+			t.MoveSelection(dx*10, dy*10)
+		})
+	}
+}
+
+// SetScreenSize 屏幕（canvas）尺寸变化
+func (t *Engine) SetScreenSize(width, height int) {
+	t.render.GetCamera().SetSize(width, height)
+	t.render.PaintAll()
+}
+
+// ShouldPan 按下中键 或按住空格按下鼠标时平移视图
+func (t *Engine) ShouldPan(button int) bool {
+	return button == 1 || t.keyboard.IsPressed("Space")
+}
+
+// PanBy 平移视图 dx dy 为屏幕上的位移
+func (t *Engine) PanBy(dx, dy int) {
+	if dx == 0 && dy == 0 {
+		return
+	}
+	t.render.GetCamera().PanBy(dx, dy)
+	t.render.PaintAll()
+}
+
+// ZoomAt 以屏幕上的一个点为中心缩放 factor 为缩放倍数 比如滚轮向上一格为1.1
+func (t *Engine) ZoomAt(x, y int, factor float64) {
+	camera := t.render.GetCamera()
+	camera.ZoomAt(x, y, camera.GetZoom()*factor)
+	t.snapper.RefreshGuides()
+	t.render.PaintAll()
+}
+
+// ZoomToFit 缩放到显示全部控件 没有控件时显示整个舞台
+func (t *Engine) ZoomToFit() {
+	root := t.boxTree.GetBoxROOT()
+	bounds := root.GetBounds()
+	for idx, box := range root.children {
+		if idx == 0 {
+			bounds = t.boxTree.GetSubtreeBounds(box)
+			continue
+		}
+		rect := unionBounds(bounds, t.boxTree.GetSubtreeBounds(box), 0)
+		bounds = Bounds{rect.x, rect.y, rect.width, rect.height}
+	}
+	t.render.GetCamera().FitBounds(bounds, FITPADDING)
+	t.snapper.RefreshGuides()
+	t.render.PaintAll()
+}
+
+// ZoomToSelection 缩放到显示所有选中的控件
+func (t *Engine) ZoomToSelection() {
+	if len(t.selection) == 0 {
+		return
+	}
+	bounds := t.boxTree.GetSubtreeBounds(t.selection[0])
+	for _, box := range t.selection[1:] {
+		rect := unionBounds(bounds, t.boxTree.GetSubtreeBounds(box), 0)
+		bounds = Bounds{rect.x, rect.y, rect.width, rect.height}
+	}
+	t.render.GetCamera().FitBounds(bounds, FITPADDING)
+	t.snapper.RefreshGuides()
+	t.render.PaintAll()
+}
+
+// LoadStyleSheet 加载样式表文本并重绘 出错时返回带行列的StyleSheetError
+func (t *Engine) LoadStyleSheet(text string) error {
+	if err := t.styleSheet.LoadStyleSheet(text); err != nil {
+		return err
+	}
+	if t.render != nil {
+		t.render.PaintAll()
+	}
+	return nil
+}
+
+// SetTheme 切换主题并重绘 主题不存在时返回错误
+func (t *Engine) SetTheme(name string) error {
+	if err := t.styleSheet.SetTheme(name); err != nil {
+		return err
+	}
+	if t.render != nil {
+		t.render.PaintAll()
+	}
+	return nil
+}
+
+// GetTheme 获取当前主题名
+func (t *Engine) GetTheme() string {
+	return t.styleSheet.GetTheme()
+}
+
+// GetThemeNames 获取所有主题名
+func (t *Engine) GetThemeNames() []string {
+	return t.styleSheet.GetThemeNames()
+}
+
+// SetBoxStyle 修改控件的类 多个类用空格分隔
+func (t *Engine) SetBoxStyle(box *Box, styleClass string) {
+	if box == nil || box.styleClass == styleClass {
+		return
+	}
+	t.history.Execute(NewStyleBoxCommand(t, box, styleClass))
+}
+
+// SetInlineStyle 修改控件的内联样式 比如 background: red; opacity: 0.5 为空时清除内联样式
+func (t *Engine) SetInlineStyle(box *Box, declarations string) error {
+	if box == nil {
+		return nil
+	}
+	var rule *StyleRule
+	if strings.TrimSpace(declarations) != "" {
+		var err error
+		if rule, err = ParseStyleDeclarations(declarations); err != nil {
+			return err
+		}
+	}
+	t.history.Execute(NewInlineStyleBoxCommand(t, box, rule))
+	return nil
+}
+
+// SetBoxName 修改控件名称 名称可以重复 为空时清除
+func (t *Engine) SetBoxName(box *Box, name string) {
+	if box == nil || box.name == name {
+		return
+	}
+	t.history.Execute(NewRenameBoxCommand(box, name))
+}
+
+// GetBoxByID 按id获取控件 已删除的控件返回nil
+func (t *Engine) GetBoxByID(id string) *Box {
+	return t.boxTree.GetBoxByID(id)
+}
+
+// FindBoxesByName 按名称查找控件
+func (t *Engine) FindBoxesByName(name string) []*Box {
+	return t.boxTree.FindBoxesByName(name)
+}
+
+// ReparentBox 把控件移动到新的容器中 x y 为新容器中的相对坐标 index为-1时放到最上层
+func (t *Engine) ReparentBox(box *Box, parent *Box, index int, x, y int) {
+	if box == nil || box.parent == nil || parent == nil || !t.CanContain(parent, box) {
+		return
+	}
+	geometry := getBoxGeometry(box)
+	geometry.x = x
+	geometry.y = y
+	t.history.Execute(NewReparentBoxCommand(t, box, parent, index, geometry))
+}
+
+// DropBoxes 把一组控件放入容器的最上层 保持控件的绝对位置和角度 已经在容器中或不能放入的控件保持不动
+// 返回是否有控件改变了容器
+func (t *Engine) DropBoxes(boxes []*Box, container *Box) bool {
+	cmd := t.newDropBoxesCommand(boxes, container)
+	if cmd == nil {
+		return false
+	}
+	t.history.Execute(cmd)
+	return true
+}
+
+// SetContainRule 设置一个类的控件可以包含哪些类的控件 * 表示任意控件 childClasses 为空时不能包含控件
+// 根节点可以包含任意控件
+func (t *Engine) SetContainRule(containerClass string, childClasses ...string) {
+	if len(childClasses) == 0 {
+		delete(t.config.containRules, containerClass)
+		return
+	}
+	t.config.containRules[containerClass] = childClasses
+}
+
+// CanContain 判断控件能否放入容器 控件不能放入自己或自己的子控件中
+// 组合的成员只能通过组合操作改变 组合只"包含"它已有的成员
+func (t *Engine) CanContain(container *Box, box *Box) bool {
+	if container == nil || box == nil || !isBoxUsed(container) {
+		return false
+	}
+	for b := container; b != nil; b = b.parent {
+		if b == box {
+			return false
+		}
+	}
+	if container == t.boxTree.GetBoxROOT() {
+		return true
+	}
+	if container.IsGroup() {
+		return box.parent == container
+	}
+	classes := box.GetClasses()
+	for _, class := range container.GetClasses() {
+		for _, child := range t.config.containRules[class] {
+			if child == "*" {
+				return true
+			}
+			for _, c := range classes {
+				if c == child {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// 找出绝对坐标(x, y)处可以放入这组控件的容器 从最上层的控件开始向上查找 没有时返回根节点
+// 这组控件和它们的子控件不参与查找
+func (t *Engine) findDropTarget(boxes []*Box, x, y int) *Box {
+	excluded := make(map[*Box]bool)
+	for _, box := range boxes {
+		excluded[box] = true
+		for _, b := range getDescendants(box) {
+			excluded[b] = true
+		}
+	}
+	hits := make([]*Box, 0)
+	for _, box := range t.boxTree.QueryPoint(x, y) {
+		if !excluded[box] && isBoxUsed(box) && box.ContainsPoint(float64(x), float64(y)) {
+			hits = append(hits, box)
+		}
+	}
+	root := t.boxTree.GetBoxROOT()
+	if len(hits) == 0 {
+		return root
+	}
+	t.boxTree.SortByZOrder(hits)
+	for container := hits[len(hits)-1]; container != root; container = container.parent {
+		accept := true
+		for _, box := range boxes {
+			if !t.CanContain(container, box) {
+				accept = false
+				break
+			}
+		}
+		if accept {
+			return container
+		}
+	}
+	return root
+}
+
+// 把一组控件放入容器的操作 按z-index顺序放到最上层 没有控件需要改变容器时返回nil
+func (t *Engine) newDropBoxesCommand(boxes []*Box, container *Box) Command {
+	list := append([]*Box{}, boxes...)
+	t.boxTree.SortByZOrder(list)
+	cmds := make([]Command, 0, len(list))
+	for _, box := range list {
+		if box.parent == nil || box.parent == container || !t.CanContain(container, box) {
+			continue
+		}
+		cmds = append(cmds, NewReparentBoxCommand(t, box, container, -1, reparentGeometry(box, container)))
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	if len(cmds) == 1 {
+		return cmds[0]
+	}
+	return NewBatchCommand(cmds...)
+}
+
+// 控件放入新容器后保持绝对位置和角度不变的几何信息
+func reparentGeometry(box *Box, parent *Box) boxGeometry {
+	geometry := getBoxGeometry(box)
+	w := float64(box.width)
+	h := float64(box.height)
+	cx, cy := box.GetTransform().Apply(w/2, h/2)
+	lx, ly := parent.GetTransform().Invert().Apply(cx, cy)
+	geometry.x = round(lx - w/2)
+	geometry.y = round(ly - h/2)
+	geometry.angle = normalizeAngle(box.GetWorldAngle() - parent.GetWorldAngle())
+	return geometry
+}
+
+// ReorderBox 调整控件在兄弟节点中的层级
+func (t *Engine) ReorderBox(box *Box, index int) {
+	if box == nil || box.parent == nil || t.boxTree.IndexOfChild(box) == index {
+		return
+	}
+	t.history.Execute(NewReorderBoxCommand(t, box, index))
+}
+
+// ReorderSelection 调整选中控件在兄弟节点中的层级 多个选中的控件一起调整 相对顺序不变 作为一步历史
+func (t *Engine) ReorderSelection(op ZOrderOperation) {
+	roots := t.selectionRoots()
+	cmds := make([]Command, 0)
+	for parent, children := range t.boxTree.ReorderedChildren(roots, op) {
+		boxes := make([]*Box, 0)
+		for _, box := range roots {
+			if box.parent == parent {
+				boxes = append(boxes, box)
+			}
+		}
+		cmds = append(cmds, NewReorderChildrenCommand(t, parent, children, boxes))
+	}
+	if len(cmds) == 0 {
+		return
+	}
+	if len(cmds) == 1 {
+		t.history.Execute(cmds[0])
+		return
+	}
+	t.history.Execute(NewBatchCommand(cmds...))
+}
+
+// Copy 复制选中的控件（连同子控件和样式）到剪贴板 返回序列化的文本 用于写入系统剪贴板 没有选中时返回nil
+func (t *Engine) Copy() []byte {
+	return t.copySelection(false)
+}
+
+// Cut 剪切选中的控件 复制后删除 第一次粘贴在原来的位置
+func (t *Engine) Cut() []byte {
+	data := t.copySelection(true)
+	if data != nil {
+		t.DeleteSelection()
+	}
+	return data
+}
+
+func (t *Engine) copySelection(cut bool) []byte {
+	boxes := t.selectionRoots()
+	if len(boxes) == 0 {
+		return nil
+	}
+	if err := t.clipboard.SetDocument(NewClipboardDocument(boxes, t.styleSheet), cut); err != nil {
+		return nil
+	}
+	return t.clipboard.Data()
+}
+
+// SetClipboardData 设置系统剪贴板中的文本 比如从另一个编辑器复制的控件 与当前剪贴板相同时保留连续粘贴的偏移
+func (t *Engine) SetClipboardData(data []byte) error {
+	return t.clipboard.SetData(data)
+}
+
+// Paste 粘贴剪贴板中的控件 每次成功粘贴相对复制时的位置多偏移一次 粘贴到进入的组合 没有进入组合时粘贴到根节点
+// 一步历史 粘贴后选中新的控件
+func (t *Engine) Paste() bool {
+	if t.clipboard.IsEmpty() {
+		return false
+	}
+	doc, offset := t.clipboard.nextPaste()
+	boxes, err := doc.Build()
+	if err != nil || !t.pasteBoxes(doc, boxes, float64(offset), float64(offset)) {
+		return false
+	}
+	t.clipboard.pasted()
+	return true
+}
+
+// PasteAt 粘贴剪贴板中的控件 控件外框的中心放在绝对坐标(x, y)处
+func (t *Engine) PasteAt(x, y int) bool {
+	if t.clipboard.IsEmpty() {
+		return false
+	}
+	doc, _ := t.clipboard.nextPaste()
+	boxes, err := doc.Build()
+	if err != nil || len(boxes) == 0 {
+		return false
+	}
+	rect := boxesRect(boxes)
+	if !t.pasteBoxes(doc, boxes, float64(x)-(rect.minX+rect.maxX)/2, float64(y)-(rect.minY+rect.maxY)/2) {
+		return false
+	}
+	t.clipboard.pasted()
+	return true
+}
+
+// Duplicate 在原来的容器中创建选中控件的副本 副本偏移一次放在最上层 不改变剪贴板 一步历史 创建后选中副本
+func (t *Engine) Duplicate() {
+	roots := t.selectionRoots()
+	if len(roots) == 0 {
+		return
+	}
+	boxes, err := NewClipboardDocument(roots, t.styleSheet).Build()
+	if err != nil {
+		return
+	}
+	cmds := make([]Command, 0, len(boxes))
+	for idx, box := range boxes {
+		box.x += PASTEOFFSET
+		box.y += PASTEOFFSET
+		t.setPastedGeometry(box, roots[idx].parent)
+		cmds = append(cmds, NewCreateBoxCommand(t, box, roots[idx].parent, -1))
+	}
+	t.history.Execute(NewBatchCommand(cmds...))
+	t.SetSelection(boxes)
+}
+
+// 把新建的控件（绝对坐标）平移后粘贴到进入的组合 没有进入组合时粘贴到根节点 添加缺少的样式规则
+// 进入组合后粘贴是明确的操作 不经过CanContain 它只允许组合已有的成员
+func (t *Engine) pasteBoxes(doc *ClipboardDocument, boxes []*Box, dx, dy float64) bool {
+	if len(boxes) == 0 {
+		return false
+	}
+	if err := doc.AddMissingStyles(t.styleSheet); err != nil {
+		return false
+	}
+	parent := t.marqueeContainer()
+	cmds := make([]Command, 0, len(boxes))
+	for _, box := range boxes {
+		box.x += round(dx)
+		box.y += round(dy)
+		t.setPastedGeometry(box, parent)
+		cmds = append(cmds, NewCreateBoxCommand(t, box, parent, -1))
+	}
+	t.history.Execute(NewBatchCommand(cmds...))
+	t.SetSelection(boxes)
+	return true
+}
+
+// 不在boxtree中的控件的坐标是绝对的 改为相对容器 保持绝对位置和角度
+func (t *Engine) setPastedGeometry(box *Box, parent *Box) {
+	geometry := reparentGeometry(box, parent)
+	box.x = geometry.x
+	box.y = geometry.y
+	box.angle = geometry.angle
+}
+
+// AlignSelection 对齐选中的控件 按旋转后的外框计算 一步历史
+// 选中多个控件时对齐到它们外框的并集 只选中一个控件时对齐到它的容器
+func (t *Engine) AlignSelection(op AlignOperation) {
+	boxes := t.selectionRoots()
+	if len(boxes) == 0 {
+		return
+	}
+	rects := make([]snapRect, len(boxes))
+	for idx, box := range boxes {
+		rects[idx] = pointsRect(box.GetCorners())
+	}
+	reference := boxesRect(boxes)
+	if len(boxes) == 1 {
+		reference = pointsRect(boxes[0].parent.GetCorners())
+	}
+	t.moveBoxesBy(boxes, alignOffsets(rects, reference, op))
+}
+
+// DistributeSelection 分布选中的控件 两端的控件不动 中间的控件等间距或中心等距排列 至少选中3个控件 一步历史
+func (t *Engine) DistributeSelection(op DistributeOperation) {
+	boxes := t.selectionRoots()
+	if len(boxes) < 3 {
+		return
+	}
+	rects := make([]snapRect, len(boxes))
+	for idx, box := range boxes {
+		rects[idx] = pointsRect(box.GetCorners())
+	}
+	t.moveBoxesBy(boxes, distributeOffsets(rects, op))
+}
+
+// 每个控件分别平移 offsets 为绝对坐标系中的位移 没有位移的控件不动 作为一步历史
+func (t *Engine) moveBoxesBy(boxes []*Box, offsets []Point) {
+	cmds := make([]Command, 0, len(boxes))
+	for idx, box := range boxes {
+		dx, dy := parentDelta(box, round(offsets[idx].x), round(offsets[idx].y))
+		if dx == 0 && dy == 0 {
+			continue
+		}
+		cmds = append(cmds, NewMoveBoxCommand(t, box, box.x+dx, box.y+dy))
+	}
+	if len(cmds) == 0 {
+		return
+	}
+	if len(cmds) == 1 {
+		t.history.Execute(cmds[0])
+		return
+	}
+	t.history.Execute(NewBatchCommand(cmds...))
+}
+
+// GroupSelection 把选中的控件组合为一个整体 选中的控件必须在同一个容器中 组合后选中组合 返回新的组合
+func (t *Engine) GroupSelection() *Box {
+	boxes := t.selectionRoots()
+	if len(boxes) == 0 {
+		return nil
+	}
+	for _, box := range boxes {
+		if box.parent != boxes[0].parent {
+			return nil
+		}
+	}
+	group := NewBox(0, 0, 0, 0, GROUPCLASS)
+	t.history.Execute(NewGroupBoxesCommand(t, boxes, group))
+	t.SelectBox(group)
+	return group
+}
+
+// UngroupSelection 解散选中的组合 一步历史 解散后选中原来的成员
+func (t *Engine) UngroupSelection() {
+	cmds := make([]Command, 0)
+	members := make([]*Box, 0)
+	for _, box := range t.selectionRoots() {
+		if box.IsGroup() {
+			cmds = append(cmds, NewUngroupBoxCommand(t, box))
+			members = append(members, box.children...)
+		}
+	}
+	if len(cmds) == 0 {
+		return
+	}
+	if len(cmds) == 1 {
+		t.history.Execute(cmds[0])
+	} else {
+		t.history.Execute(NewBatchCommand(cmds...))
+	}
+	t.SetSelection(members)
+}
+
+// EnterGroup 进入组合 之后可以单独选中和编辑组合中的成员 组合外的控件仍然可以点击
+func (t *Engine) EnterGroup(group *Box) {
+	if group == nil || group.parent == nil || !group.IsGroup() {
+		return
+	}
+	t.setEnteredGroup(group)
+}
+
+// ExitGroup 退出当前进入的组合并选中它 组合嵌套时回到外层组合
+func (t *Engine) ExitGroup() {
+	group := t.enteredGroup
+	if group == nil {
+		return
+	}
+	t.setEnteredGroup(enclosingGroup(group))
+	t.SelectBox(group)
+}
+
+// GetEnteredGroup 获取当前进入的组合 没有进入组合时返回nil
+func (t *Engine) GetEnteredGroup() *Box {
+	return t.enteredGroup
+}
+
+// 设置进入的组合 更新鼠标事件的范围和交互层的组合范围
+func (t *Engine) setEnteredGroup(group *Box) {
+	if group == t.enteredGroup {
+		return
+	}
+	if t.groupScope != nil {
+		t.groupScope.Close()
+		t.groupScope = nil
+	}
+	t.enteredGroup = group
+	t.mouseEvent.SetScope(group)
+	if group != nil {
+		t.groupScope = NewBoxBorderView(group, "groupscope", t)
+		t.groupScope.Render()
+	}
+}
+
+// 框选的范围 进入组合时框选组合中的成员
+func (t *Engine) marqueeContainer() *Box {
+	if t.enteredGroup != nil {
+		return t.enteredGroup
+	}
+	return t.boxTree.GetBoxROOT()
+}
+
+// 包含控件的最近一层组合 没有时返回nil
+func enclosingGroup(box *Box) *Box {
+	for b := box.parent; b != nil; b = b.parent {
+		if b.IsGroup() {
+			return b
+		}
+	}
+	return nil
+}
+
+// Undo 撤销
+func (t *Engine) Undo() bool {
+	return t.history.Undo()
+}
+
+// Redo 重做
+func (t *Engine) Redo() bool {
+	return t.history.Redo()
+}
+
+// AddHistoryHandler 监听历史变化 用于页面启用或禁用撤销重做按钮
+func (t *Engine) AddHistoryHandler(handler HistoryHandler) {
+	t.history.AddHistoryHandler(handler)
+}
+
+// Save 把当前地图保存为json文档
+func (t *Engine) Save() ([]byte, error) {
+	return NewDocument(t.boxTree, t.styleSheet).Encode()
+}
+
+// ExportSVG 导出SVG selectionOnly 为true时只导出选中的控件 attributes 为true时输出控件的id 名称和class
+func (t *Engine) ExportSVG(selectionOnly, attributes bool) ([]byte, error) {
+	exporter := NewSVGExporter(t.boxTree, t.styleSheet, SVGOptions{Attributes: attributes, Background: !selectionOnly})
+	if !selectionOnly {
+		return exporter.ExportStage(), nil
+	}
+	if len(t.selection) == 0 {
+		return nil, errors.New("engine: nothing selected")
+	}
+	return exporter.ExportBoxes(t.selectionRoots()), nil
+}
+
+// Load 加载json文档 替换当前地图 舞台尺寸改为文档的尺寸 清空历史并重绘
+// 文档有错误时返回错误 当前地图和历史不变
+func (t *Engine) Load(data []byte) error {
+	doc, err := DecodeDocument(data)
+	if err != nil {
+		return err
+	}
+	if doc.Width <= 0 || doc.Height <= 0 {
+		return fmt.Errorf("document: invalid size %dx%d", doc.Width, doc.Height)
+	}
+	content, err := doc.convert(t.styleSheet)
+	if err != nil {
+		return err
+	}
+
+	root := t.boxTree.GetBoxROOT()
+	for len(root.children) > 0 {
+		box := root.children[len(root.children)-1]
+		t.removeBox(box)
+	}
+	t.boxTree.SetSize(doc.Width, doc.Height)
+
+	content.apply(t.boxTree, t.styleSheet)
+	for _, box := range getDescendants(root) {
+		t.stateMachines[box] = BoxStateMachineFactroy(box, t)
+	}
+	t.history.Clear()
+	t.snapper.RefreshGuides()
+	t.render.PaintAll()
+	return nil
+}
+
+// 把控件（连同子控件）挂到容器中 为新控件创建状态机 并重绘
+func (t *Engine) attachBox(box *Box, parent *Box, index int) {
+	t.boxTree.InsertBox(box, parent, index)
+	t.styleSheet.InvalidateStyle(box)
+	for _, b := range append([]*Box{box}, getDescendants(box)...) {
+		if _, ok := t.stateMachines[b]; !ok {
+			t.stateMachines[b] = BoxStateMachineFactroy(b, t)
+		}
+	}
+	t.render.PaintBox(box)
+}
+
+// 把控件（连同子控件）从boxtree中摘下 重置交互状态 并重绘空出的区域 进入的组合被摘下时退出到外层
+func (t *Engine) detachBox(box *Box) {
+	bounds := t.boxTree.GetSubtreeBounds(box)
+	if t.enteredGroup != nil && (t.enteredGroup == box || isAncestor(box, t.enteredGroup)) {
+		t.setEnteredGroup(enclosingGroup(box))
+	}
+	for _, b := range append([]*Box{box}, getDescendants(box)...) {
+		if b.IsSelected() {
+			t.RemoveFromSelection(b)
+		}
+		t.mouseEvent.ResetBoxState(b)
+		if machine, ok := t.stateMachines[b]; ok {
+			machine.OpenState(NORMAL)
+		}
+	}
+	t.boxTree.RemoveBox(box)
+	t.render.PaintRectArea(&Rect{bounds.x, bounds.y, bounds.width, bounds.height})
+}
+
+// 释放已经摘下的控件（连同子控件）的事件监听和状态机
+func (t *Engine) releaseBox(box *Box) {
+	for _, b := range append([]*Box{box}, getDescendants(box)...) {
+		if machine, ok := t.stateMachines[b]; ok {
+			machine.Release()
+			delete(t.stateMachines, b)
+		}
+		t.mouseEvent.RemoveEvents(b)
+		t.keyboard.RemoveEvents(b)
+		t.styleSheet.InvalidateStyle(b)
+	}
+}
+
+// 删除控件（连同子控件） 摘下后释放事件监听和状态机 撤销时由attachBox重新创建
+func (t *Engine) removeBox(box *Box) {
+	t.detachBox(box)
+	t.releaseBox(box)
+}
+
+// 重绘一组控件（连同子控件）所在的区域
+func (t *Engine) paintBoxes(boxes []*Box) {
+	for _, box := range boxes {
+		bounds := t.boxTree.GetSubtreeBounds(box)
+		t.render.PaintRectArea(&Rect{bounds.x, bounds.y, bounds.width, bounds.height})
+	}
+}
+
+// 把控件（连同子控件）移动到新的容器中 保留选中和交互状态 刷新交互视图 并重绘新旧区域
+func (t *Engine) setBoxParent(box *Box, parent *Box, index int, geometry boxGeometry) {
+	ob := t.boxTree.GetSubtreeBounds(box)
+	t.boxTree.RemoveBox(box)
+	box.x = geometry.x
+	box.y = geometry.y
+	box.width = geometry.width
+	box.height = geometry.height
+	box.angle = geometry.angle
+	t.boxTree.InsertBox(box, parent, index)
+	t.styleSheet.InvalidateStyle(box)
+	for _, b := range append([]*Box{box}, getDescendants(box)...) {
+		if machine, ok := t.stateMachines[b]; ok {
+			machine.Refresh()
+		}
+	}
+	t.render.PaintRectArea(unionBounds(ob, t.boxTree.GetSubtreeBounds(box), RESIZEHANDLESIZE+ROTATEHANDLEOFFSET))
+}
+
+// 修改控件的几何信息 子控件跟随移动和旋转 刷新交互视图 并重绘新旧区域
+func (t *Engine) setBoxGeometry(box *Box, geometry boxGeometry) {
+	t.setBoxesGeometry(box, map[*Box]boxGeometry{box: geometry})
+}
+
+// 同时修改控件和它的后代的几何信息 比如拉伸组合时缩放成员 geometries 中没有的后代保持不变
+func (t *Engine) setBoxesGeometry(box *Box, geometries map[*Box]boxGeometry) {
+	ob := t.boxTree.GetSubtreeBounds(box)
+	for b, geometry := range geometries {
+		b.x = geometry.x
+		b.y = geometry.y
+		b.width = geometry.width
+		b.height = geometry.height
+		b.angle = geometry.angle
+	}
+	t.boxTree.UpdateBox(box)
+	for _, b := range append([]*Box{box}, getDescendants(box)...) {
+		if machine, ok := t.stateMachines[b]; ok {
+			machine.Refresh()
+		}
+	}
+	if t.groupScope != nil {
+		t.groupScope.Refresh()
+	}
+	t.render.PaintRectArea(unionBounds(ob, t.boxTree.GetSubtreeBounds(box), RESIZEHANDLESIZE+ROTATEHANDLEOFFSET))
+}
+
+// 把一组兄弟控件放入组合 为组合创建状态机 刷新成员的交互视图 并重绘
+func (t *Engine) groupBoxes(boxes []*Box, group *Box) {
+	if !t.boxTree.Group(boxes, group) {
+		return
+	}
+	t.refreshGroup(group)
+}
+
+// 解散组合 成员放回组合的容器 释放组合的事件监听和状态机 返回成员
+func (t *Engine) ungroupBox(group *Box) []*Box {
+	bounds := t.boxTree.GetSubtreeBounds(group)
+	if t.enteredGroup == group {
+		t.setEnteredGroup(enclosingGroup(group))
+	}
+	if group.IsSelected() {
+		t.RemoveFromSelection(group)
+	}
+	t.mouseEvent.ResetBoxState(group)
+	if machine, ok := t.stateMachines[group]; ok {
+		machine.OpenState(NORMAL)
+	}
+	members := t.boxTree.Ungroup(group)
+	t.releaseBox(group)
+	for _, box := range members {
+		t.styleSheet.InvalidateStyle(box)
+		for _, b := range append([]*Box{box}, getDescendants(box)...) {
+			if machine, ok := t.stateMachines[b]; ok {
+				machine.Refresh()
+			}
+		}
+	}
+	t.render.PaintRectArea(&Rect{bounds.x, bounds.y, bounds.width, bounds.height})
+	return members
+}
+
+// 恢复解散前的组合 组合和成员的几何信息恢复为解散前的值
+func (t *Engine) restoreGroup(group *Box, parent *Box, index int, geometry boxGeometry, members []*Box, geometries []boxGeometry) {
+	t.paintBoxes(members)
+	for _, box := range members {
+		t.boxTree.RemoveBox(box)
+	}
+	group.x = geometry.x
+	group.y = geometry.y
+	group.width = geometry.width
+	group.height = geometry.height
+	group.angle = geometry.angle
+	t.boxTree.InsertBox(group, parent, index)
+	for idx, box := range members {
+		box.x = geometries[idx].x
+		box.y = geometries[idx].y
+		box.width = geometries[idx].width
+		box.height = geometries[idx].height
+		box.angle = geometries[idx].angle
+		t.boxTree.InsertBox(box, group, -1)
+	}
+	t.refreshGroup(group)
+}
+
+// 组合的结构变化后 为组合创建状态机 清除成员的计算样式 刷新交互视图 并重绘
+func (t *Engine) refreshGroup(group *Box) {
+	if _, ok := t.stateMachines[group]; !ok {
+		t.stateMachines[group] = BoxStateMachineFactroy(group, t)
+	}
+	t.styleSheet.InvalidateStyle(group)
+	for _, b := range getDescendants(group) {
+		if machine, ok := t.stateMachines[b]; ok {
+			machine.Refresh()
+		}
+	}
+	t.paintBoxes([]*Box{group})
+}
+
+// GetSelectedBox 获取当前选中的控件 多选时返回最后选中的一个
+func (t *Engine) GetSelectedBox() *Box {
+	if len(t.selection) == 0 {
+		return nil
+	}
+	return t.selection[len(t.selection)-1]
+}
+
+// GetSelection 获取所有选中的控件 按选中的先后顺序
+func (t *Engine) GetSelection() []*Box {
+	selection := make([]*Box, len(t.selection))
+	copy(selection, t.selection)
+	return selection
+}
+
+// IsMultiSelection 是否选中了多个控件
+func (t *Engine) IsMultiSelection() bool {
+	return len(t.selection) > 1
+}
+
+// SelectBox 只选中一个控件 box为nil或根节点时取消选中
+func (t *Engine) SelectBox(box *Box) {
+	if box == nil {
+		t.SetSelection(nil)
+		return
+	}
+	t.SetSelection([]*Box{box})
+}
+
+// AddToSelection 添加到选中的控件中
+func (t *Engine) AddToSelection(box *Box) {
+	if box == nil || box.IsSelected() {
+		return
+	}
+	t.SetSelection(append(t.GetSelection(), box))
+}
+
+// RemoveFromSelection 从选中的控件中去掉
+func (t *Engine) RemoveFromSelection(box *Box) {
+	if box == nil || !box.IsSelected() {
+		return
+	}
+	t.SetSelection(removeBoxFromList(t.GetSelection(), box))
+}
+
+// ToggleSelection 切换控件的选中状态 用于Shift Ctrl点击
+func (t *Engine) ToggleSelection(box *Box) {
+	if box == nil {
+		return
+	}
+	if box.IsSelected() {
+		t.RemoveFromSelection(box)
+	} else {
+		t.AddToSelection(box)
+	}
+}
+
+// SetSelection 设置选中的控件 根节点 不在boxtree中的控件和重复的控件会被忽略
+func (t *Engine) SetSelection(boxes []*Box) {
+	selection := make([]*Box, 0, len(boxes))
+	selected := make(map[*Box]bool, len(boxes))
+	for _, box := range boxes {
+		if box == nil || box.parent == nil || selected[box] {
+			continue
+		}
+		selected[box] = true
+		selection = append(selection, box)
+	}
+	old := t.selection
+	if len(old) == len(selection) {
+		same := true
+		for idx, box := range old {
+			if selection[idx] != box {
+				same = false
+				break
+			}
+		}
+		if same {
+			return
+		}
+	}
+	t.selection = selection
+	//选中了进入的组合之外的控件时 退出到包含这些控件的外层组合
+	scope := t.enteredGroup
+	for _, box := range selection {
+		for scope != nil && !isAncestor(scope, box) {
+			scope = enclosingGroup(scope)
+		}
+	}
+	t.setEnteredGroup(scope)
+
+	for _, box := range old {
+		if selected[box] {
+			continue
+		}
+		box.SetIsSelected(false)
+		t.styleSheet.InvalidateStyle(box)
+		if machine, ok := t.stateMachines[box]; ok {
+			machine.DispatchEvent(UNSELECT)
+		}
+	}
+	//单选时显示控制点 多选时只显示边框 仍然选中的控件需要重新开始选中状态
+	modeChanged := (len(old) == 1) != (len(selection) == 1)
+	for _, box := range selection {
+		machine := t.stateMachines[box]
+		if box.IsSelected() {
+			if modeChanged && machine != nil && machine.CurrentState() == SELECTED {
+				machine.Restart()
+			}
+			continue
+		}
+		box.SetIsSelected(true)
+		t.styleSheet.InvalidateStyle(box)
+		if machine != nil {
+			machine.DispatchEvent(SELECT)
+		}
+	}
+	t.keyboard.SetFocus(t.GetSelectedBox())
+
+	for _, handler := range t.selectionHandlers {
+		handler(t.GetSelection())
+	}
+}
+
+// AddSelectionHandler 监听选中变化
+func (t *Engine) AddSelectionHandler(handler SelectionHandler) {
+	t.selectionHandlers = append(t.selectionHandlers, handler)
+}
+
+// 选中的控件中祖先没有被选中的控件 按照z-index排序 避免父子控件被重复操作
+func (t *Engine) selectionRoots() []*Box {
+	roots := make([]*Box, 0, len(t.selection))
+	for _, box := range t.selection {
+		isRoot := true
+		for p := box.parent; p != nil; p = p.parent {
+			if p.IsSelected() {
+				isRoot = false
+				break
+			}
+		}
+		if isRoot {
+			roots = append(roots, box)
+		}
+	}
+	t.boxTree.SortByZOrder(roots)
+	return roots
+}
+
+// 把一组控件从开始位置平移 dx dy 为绝对坐标系中的位移 按每个控件父节点的旋转转换到父节点的坐标系
+// 单个控件时不包装为批量操作
+func (t *Engine) newMoveBoxesCommand(boxes []*Box, origins []boxGeometry, dx, dy int) Command {
+	if len(boxes) == 1 {
+		lx, ly := parentDelta(boxes[0], dx, dy)
+		return NewMoveBoxCommand(t, boxes[0], origins[0].x+lx, origins[0].y+ly)
+	}
+	cmds := make([]Command, 0, len(boxes))
+	for idx, box := range boxes {
+		lx, ly := parentDelta(box, dx, dy)
+		cmds = append(cmds, NewMoveBoxCommand(t, box, origins[idx].x+lx, origins[idx].y+ly))
+	}
+	return NewBatchCommand(cmds...)
+}
+
+// 把绝对坐标系中的位移转换到控件父节点的坐标系
+func parentDelta(box *Box, dx, dy int) (int, int) {
+	lx, ly := box.GetParentTransform().Invert().ApplyVector(float64(dx), float64(dy))
+	return round(lx), round(ly)
+}
+
+// SetMinBoxSize 设置拉伸时的最小尺寸
+func (t *Engine) SetMinBoxSize(width, height int) {
+	t.config.minWidth = intMax(width, 1)
+	t.config.minHeight = intMax(height, 1)
+}
+
+// SetKeepAspectRatio 设置拖拽四角时是否保持宽高比
+func (t *Engine) SetKeepAspectRatio(keep bool) {
+	t.config.keepAspectRatio = keep
+}
+
+// SetRotateSnap 设置旋转吸附的角度增量 单位为度 比如15 小于等于0时不按增量吸附
+func (t *Engine) SetRotateSnap(degrees float64) {
+	t.config.rotateSnap = math.Max(degrees, 0) * math.Pi / 180
+}
+
+// SetSnapGrid 设置网格吸附的间距 小于等于0时不吸附到网格
+func (t *Engine) SetSnapGrid(size int) {
+	t.config.snapGrid = intMax(size, 0)
+}
+
+// SetSnapToBoxes 设置是否吸附到其他控件的边和中心
+func (t *Engine) SetSnapToBoxes(snap bool) {
+	t.config.snapToBoxes = snap
+}
+
+// SetSnapTolerance 设置吸附距离 单位为屏幕像素 与缩放无关
+func (t *Engine) SetSnapTolerance(pixels int) {
+	t.config.snapTolerance = intMax(pixels, 0)
+}
+
+// AddGuide 添加参考线 vertical 为true时是x=position的竖线 否则是y=position的横线 拖拽时控件吸附到参考线
+func (t *Engine) AddGuide(vertical bool, position int) *Guide {
+	return t.snapper.AddGuide(vertical, position)
+}
+
+// RemoveGuide 删除参考线
+func (t *Engine) RemoveGuide(guide *Guide) {
+	t.snapper.RemoveGuide(guide)
+}
+
+// GetGuides 获取所有参考线
+func (t *Engine) GetGuides() []*Guide {
+	return t.snapper.GetGuides()
+}
+
+// AddBoxEventHandler 监听所有控件的行为
+func (t *Engine) AddBoxEventHandler(handler BoxEventHandler) {
+	t.boxEventHandlers = append(t.boxEventHandlers, handler)
+}
 
-	BoxStateMachineFactroy(box, t)
+// 分发控件行为
+func (t *Engine) dispatchBoxEvent(box *Box, event BoxEvent) {
+	for _, handler := range t.boxEventHandlers {
+		handler(box, event)
+	}
 }
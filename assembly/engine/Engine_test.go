@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+// 没有屏幕输出的引擎 舞台中有两个控件
+func newTestEngine(t *testing.T) (*Engine, *Box, *Box) {
+	engine := NewEngine()
+	if err := engine.LoadStyleSheet(".box { background: #3366cc; border: 1px solid #202020; }"); err != nil {
+		t.Fatal(err)
+	}
+	engine.InitStage(800, 600, nil)
+	root := engine.boxTree.GetBoxROOT()
+	a := NewBox(100, 100, 50, 50, "box")
+	b := NewBox(200, 100, 50, 50, "box")
+	engine.history.Execute(NewCreateBoxCommand(engine, a, root, -1))
+	engine.history.Execute(NewCreateBoxCommand(engine, b, root, -1))
+	return engine, a, b
+}
+
+// 进入组合后粘贴和复制的控件成为组合的成员
+func TestPasteIntoEnteredGroup(t *testing.T) {
+	engine, a, b := newTestEngine(t)
+	engine.SetSelection([]*Box{a, b})
+	group := engine.GroupSelection()
+	if group == nil {
+		t.Fatal("组合失败")
+	}
+	engine.EnterGroup(group)
+	engine.SelectBox(a)
+	if engine.Copy() == nil {
+		t.Fatal("复制失败")
+	}
+
+	paste := func(name string, do func() bool) {
+		if !do() {
+			t.Fatalf("%s: 失败", name)
+		}
+		for _, box := range engine.GetSelection() {
+			if box == a || box.parent != group {
+				t.Errorf("%s: 新控件的容器是 %p 不是进入的组合 %p", name, box.parent, group)
+			}
+		}
+		engine.SelectBox(a)
+	}
+	paste("Paste", engine.Paste)
+	paste("PasteAt", func() bool { return engine.PasteAt(400, 300) })
+	paste("Duplicate", func() bool { engine.Duplicate(); return true })
+	if n := len(group.children); n != 5 {
+		t.Fatalf("组合有 %d 个成员 want 5", n)
+	}
+
+	// 退出组合后粘贴到根节点
+	engine.ExitGroup()
+	if !engine.Paste() {
+		t.Fatal("退出后粘贴失败")
+	}
+	for _, box := range engine.GetSelection() {
+		if box.parent != engine.boxTree.GetBoxROOT() {
+			t.Errorf("退出组合后 新控件的容器是 %p 不是根节点", box.parent)
+		}
+	}
+}
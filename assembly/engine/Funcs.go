@@ -65,3 +65,43 @@ func srotate(angle float64, valuex, valuey, pointx, pointy int) (int, int) {
 	sRotatey := float64(valuey-pointy)*math.Cos(angle) - float64(valuex-pointx)*math.Sin(angle) + float64(pointy)
 	return round(sRotatex), round(sRotatey)
 }
+
+// 旋转 与gg.RotateAbout方向一致 一个点绕另一个点旋转后的坐标（浮点）
+func rotatePoint(angle float64, valuex, valuey, pointx, pointy float64) (float64, float64) {
+	sin, cos := math.Sincos(angle)
+	dx := valuex - pointx
+	dy := valuey - pointy
+	return dx*cos - dy*sin + pointx, dx*sin + dy*cos + pointy
+}
+
+// 把角度规范到[0, 2π) 接近2π的浮点误差归为0
+func normalizeAngle(angle float64) float64 {
+	angle = math.Mod(angle, 2*math.Pi)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	if 2*math.Pi-angle < 1e-9 {
+		angle = 0
+	}
+	return angle
+}
+
+// 两个bounds的并集 padding 向外扩展的像素
+func unionBounds(b1, b2 Bounds, padding int) *Rect {
+	rect := &Rect{}
+	rect.x = intMin(b1.x, b2.x) - padding
+	rect.y = intMin(b1.y, b2.y) - padding
+	rect.width = intMax(b1.x+b1.width, b2.x+b2.width) + padding - rect.x
+	rect.height = intMax(b1.y+b1.height, b2.y+b2.height) + padding - rect.y
+	return rect
+}
+
+// 两个矩形的交集 不相交时宽高为0
+func clipRect(r1, r2 *Rect) *Rect {
+	rect := &Rect{}
+	rect.x = intMax(r1.x, r2.x)
+	rect.y = intMax(r1.y, r2.y)
+	rect.width = intMax(intMin(r1.x+r1.width, r2.x+r2.width)-rect.x, 0)
+	rect.height = intMax(intMin(r1.y+r1.height, r2.y+r2.height)-rect.y, 0)
+	return rect
+}
@@ -0,0 +1,111 @@
+package main
+
+import "math"
+
+// Point 浮点坐标
+type Point struct {
+	x, y float64
+}
+
+// Matrix 仿射变换 与canvas和gg一致 x' = a*x + c*y + e  y' = b*x + d*y + f
+type Matrix struct {
+	a, b, c, d, e, f float64
+}
+
+// IdentityMatrix 单位矩阵
+func IdentityMatrix() Matrix {
+	return Matrix{1, 0, 0, 1, 0, 0}
+}
+
+// TranslateMatrix 平移
+func TranslateMatrix(x, y float64) Matrix {
+	return Matrix{1, 0, 0, 1, x, y}
+}
+
+// RotateMatrix 旋转 方向与gg.Rotate一致
+func RotateMatrix(angle float64) Matrix {
+	sin, cos := math.Sincos(angle)
+	return Matrix{cos, sin, -sin, cos, 0, 0}
+}
+
+// RotateAboutMatrix 绕一个点旋转
+func RotateAboutMatrix(angle, x, y float64) Matrix {
+	return TranslateMatrix(x, y).Multiply(RotateMatrix(angle)).Multiply(TranslateMatrix(-x, -y))
+}
+
+// Multiply 矩阵相乘 结果先做n的变换再做t的变换
+func (t Matrix) Multiply(n Matrix) Matrix {
+	return Matrix{
+		t.a*n.a + t.c*n.b,
+		t.b*n.a + t.d*n.b,
+		t.a*n.c + t.c*n.d,
+		t.b*n.c + t.d*n.d,
+		t.a*n.e + t.c*n.f + t.e,
+		t.b*n.e + t.d*n.f + t.f,
+	}
+}
+
+// Apply 变换一个点
+func (t Matrix) Apply(x, y float64) (float64, float64) {
+	return t.a*x + t.c*y + t.e, t.b*x + t.d*y + t.f
+}
+
+// ApplyVector 变换一个向量 不包含平移
+func (t Matrix) ApplyVector(x, y float64) (float64, float64) {
+	return t.a*x + t.c*y, t.b*x + t.d*y
+}
+
+// Invert 逆矩阵 仿射矩阵不可逆时返回单位矩阵
+func (t Matrix) Invert() Matrix {
+	det := t.a*t.d - t.b*t.c
+	if det == 0 {
+		return IdentityMatrix()
+	}
+	return Matrix{
+		t.d / det,
+		-t.b / det,
+		-t.c / det,
+		t.a / det,
+		(t.c*t.f - t.d*t.e) / det,
+		(t.b*t.e - t.a*t.f) / det,
+	}
+}
+
+// Angle 变换包含的旋转角度
+func (t Matrix) Angle() float64 {
+	return math.Atan2(t.b, t.a)
+}
+
+// 分离轴检测两个凸多边形是否重叠 只接触边缘不算重叠
+func polygonsOverlap(p1, p2 []Point) bool {
+	for _, polygon := range [][]Point{p1, p2} {
+		for idx := range polygon {
+			next := polygon[(idx+1)%len(polygon)]
+			//边的法线作为分离轴
+			ax := -(next.y - polygon[idx].y)
+			ay := next.x - polygon[idx].x
+			if ax == 0 && ay == 0 {
+				continue
+			}
+			min1, max1 := projectPolygon(p1, ax, ay)
+			min2, max2 := projectPolygon(p2, ax, ay)
+			eps := 1e-9 * math.Hypot(ax, ay)
+			if max1 <= min2+eps || max2 <= min1+eps {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// 多边形在轴上的投影区间
+func projectPolygon(polygon []Point, ax, ay float64) (float64, float64) {
+	min := math.Inf(1)
+	max := math.Inf(-1)
+	for _, p := range polygon {
+		v := p.x*ax + p.y*ay
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	return min, max
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// 矩形的四个角 顺序为 左上 右上 右下 左下
+func rectPoints(x, y, w, h float64) []Point {
+	return []Point{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}
+}
+
+// 边长为side的正方形绕中心旋转45°后的四个角
+func diamondPoints(cx, cy, side float64) []Point {
+	r := side / math.Sqrt2
+	return []Point{{cx, cy - r}, {cx + r, cy}, {cx, cy + r}, {cx - r, cy}}
+}
+
+func TestPolygonsOverlap(t *testing.T) {
+	square := rectPoints(0, 0, 10, 10)
+	cases := []struct {
+		name string
+		p1   []Point
+		p2   []Point
+		want bool
+	}{
+		{"相交", square, rectPoints(5, 5, 10, 10), true},
+		{"相同", square, rectPoints(0, 0, 10, 10), true},
+		{"包含", square, rectPoints(2, 2, 3, 3), true},
+		{"分离", square, rectPoints(20, 0, 10, 10), false},
+		{"边接触", square, rectPoints(10, 0, 10, 10), false},
+		{"角接触", square, rectPoints(10, 10, 10, 10), false},
+		{"边重合一部分", square, rectPoints(0, 10, 5, 5), false},
+		{"45°相交", square, diamondPoints(12, 12, 10), true},
+		{"45°外框相交但分离", square, diamondPoints(15, 15, 10), false},
+		{"45°边与角接触", square, []Point{{8, 12}, {12, 8}, {16, 12}, {12, 16}}, false},
+		{"45°顶点插入边", square, []Point{{5, 9}, {9, 13}, {5, 17}, {1, 13}}, true},
+		{"两个45°相交", diamondPoints(0, 0, 10), diamondPoints(5, 5, 10), true},
+		{"两个45°边接触", diamondPoints(0, 0, 10), diamondPoints(10/math.Sqrt2, 10/math.Sqrt2, 10), false},
+	}
+	for _, c := range cases {
+		if got := polygonsOverlap(c.p1, c.p2); got != c.want {
+			t.Errorf("%s: polygonsOverlap = %v, want %v", c.name, got, c.want)
+		}
+		if got := polygonsOverlap(c.p2, c.p1); got != c.want {
+			t.Errorf("%s (交换): polygonsOverlap = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 无浏览器环境下的入口 把地图文档渲染为png或导出为svg
+// 用法: go build -o xmaprender ./assembly/engine && xmaprender -in map.json -out map.png -zoom 0.5 -clip 0,0,800,600 -theme dark
+// 需要按目录编译 列出文件编译时不会按构建约束排除浏览器中的文件
+func main() {
+	input := flag.String("in", "", "地图文档（json）")
+	output := flag.String("out", "map.png", "输出的png文件 扩展名为.svg时导出SVG")
+	zoom := flag.Float64("zoom", 1, "缩放比例")
+	clip := flag.String("clip", "", "裁剪区域 x,y,width,height 缩放前的坐标 默认整个舞台")
+	theme := flag.String("theme", "", "主题 默认使用文档中保存的主题")
+	flag.Parse()
+
+	if err := renderDocument(*input, *output, *zoom, *clip, *theme); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func renderDocument(input, output string, zoom float64, clip, theme string) error {
+	if input == "" {
+		return fmt.Errorf("missing -in")
+	}
+	if zoom <= 0 {
+		return fmt.Errorf("invalid zoom %v", zoom)
+	}
+	data, err := ioutil.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	doc, err := DecodeDocument(data)
+	if err != nil {
+		return err
+	}
+
+	tree := NewBoxTree(doc.Width, doc.Height)
+	styleSheet := NewStyleSheetManager()
+	if err := doc.Build(tree, styleSheet); err != nil {
+		return err
+	}
+	if theme != "" {
+		if err := styleSheet.SetTheme(theme); err != nil {
+			return err
+		}
+	}
+	if strings.HasSuffix(strings.ToLower(output), ".svg") {
+		exporter := NewSVGExporter(tree, styleSheet, SVGOptions{Attributes: true, Background: true})
+		return ioutil.WriteFile(output, exporter.ExportStage(), 0644)
+	}
+	render := NewRenderEngine(tree, styleSheet, nil)
+
+	root := tree.GetBoxROOT()
+	rect := &Rect{root.x, root.y, root.width, root.height}
+	if clip != "" {
+		if rect, err = parseRect(clip); err != nil {
+			return err
+		}
+	}
+	return render.SavePNG(output, rect, zoom)
+}
+
+// 解析 x,y,width,height
+func parseRect(value string) (*Rect, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid rect %q", value)
+	}
+	values := make([]int, 4)
+	for idx, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rect %q", value)
+		}
+		values[idx] = n
+	}
+	if values[2] <= 0 || values[3] <= 0 {
+		return nil, fmt.Errorf("invalid rect %q", value)
+	}
+	return &Rect{values[0], values[1], values[2], values[3]}, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+// Command 可撤销的操作
+type Command interface {
+	Do()                     //执行
+	Undo()                   //撤销
+	Merge(next Command) bool //合并一个紧随其后的操作 合并成功返回true
+}
+
+// mergeChecker 合并之前可以判断能否合并的操作 批量操作先检查所有子操作 没有实现的操作在批量操作中不合并
+type mergeChecker interface {
+	CanMerge(next Command) bool
+}
+
+// HistoryHandler 历史变化回调
+type HistoryHandler func(canUndo, canRedo bool)
+
+// History 操作历史
+type History struct {
+	undoStack []Command
+	redoStack []Command
+	merging   bool //栈顶的操作是否还可以合并 连续操作（比如拖拽）结束时关闭
+	handlers  []HistoryHandler
+}
+
+// NewHistory 构造函数
+func NewHistory() (history *History) {
+	history = &History{}
+	history.undoStack = make([]Command, 0)
+	history.redoStack = make([]Command, 0)
+	history.merging = false
+	history.handlers = make([]HistoryHandler, 0)
+	return history
+}
+
+// Execute 执行一个操作并记录
+func (t *History) Execute(cmd Command) {
+	cmd.Do()
+	t.push(cmd)
+	t.merging = false
+	t.notify()
+}
+
+// ExecuteMerge 执行一个连续操作 尽量合并到栈顶的操作中 直到Seal为止只产生一步历史
+func (t *History) ExecuteMerge(cmd Command) {
+	cmd.Do()
+	size := len(t.undoStack)
+	if !t.merging || size == 0 || !t.undoStack[size-1].Merge(cmd) {
+		t.push(cmd)
+	}
+	t.merging = true
+	t.notify()
+}
+
+// ExecuteAppend 执行一个操作 连续操作还没有结束时和栈顶的操作组合为一步历史 比如拖拽结束时放入容器
+func (t *History) ExecuteAppend(cmd Command) {
+	cmd.Do()
+	size := len(t.undoStack)
+	if t.merging && size > 0 {
+		t.undoStack[size-1] = NewBatchCommand(t.undoStack[size-1], cmd)
+	} else {
+		t.push(cmd)
+	}
+	t.notify()
+}
+
+// Seal 结束连续操作 之后的操作不再合并
+func (t *History) Seal() {
+	t.merging = false
+}
+
+// Undo 撤销
+func (t *History) Undo() bool {
+	size := len(t.undoStack)
+	if size == 0 {
+		return false
+	}
+	cmd := t.undoStack[size-1]
+	t.undoStack = t.undoStack[:size-1]
+	t.merging = false
+	cmd.Undo()
+	t.redoStack = append(t.redoStack, cmd)
+	t.notify()
+	return true
+}
+
+// Redo 重做
+func (t *History) Redo() bool {
+	size := len(t.redoStack)
+	if size == 0 {
+		return false
+	}
+	cmd := t.redoStack[size-1]
+	t.redoStack = t.redoStack[:size-1]
+	t.merging = false
+	cmd.Do()
+	t.undoStack = append(t.undoStack, cmd)
+	t.notify()
+	return true
+}
+
+// CanUndo 是否可以撤销
+func (t *History) CanUndo() bool {
+	return len(t.undoStack) > 0
+}
+
+// CanRedo 是否可以重做
+func (t *History) CanRedo() bool {
+	return len(t.redoStack) > 0
+}
+
+// Clear 清空历史
+func (t *History) Clear() {
+	t.undoStack = t.undoStack[:0]
+	t.redoStack = t.redoStack[:0]
+	t.merging = false
+	t.notify()
+}
+
+// AddHistoryHandler 监听历史变化
+func (t *History) AddHistoryHandler(handler HistoryHandler) {
+	t.handlers = append(t.handlers, handler)
+}
+
+// 入栈 新的操作会清空重做栈
+func (t *History) push(cmd Command) {
+	t.undoStack = append(t.undoStack, cmd)
+	t.redoStack = t.redoStack[:0]
+}
+
+func (t *History) notify() {
+	canUndo := t.CanUndo()
+	canRedo := t.CanRedo()
+	for _, handler := range t.handlers {
+		handler(canUndo, canRedo)
+	}
+}
+
+// BatchCommand 把多个操作组合为一步历史 撤销时按相反的顺序撤销
+type BatchCommand struct {
+	commands []Command
+}
+
+// NewBatchCommand 构造函数
+func NewBatchCommand(commands ...Command) *BatchCommand {
+	return &BatchCommand{commands}
+}
+
+// Do 执行
+func (t *BatchCommand) Do() {
+	for _, cmd := range t.commands {
+		cmd.Do()
+	}
+}
+
+// Undo 撤销
+func (t *BatchCommand) Undo() {
+	for idx := len(t.commands) - 1; idx >= 0; idx-- {
+		t.commands[idx].Undo()
+	}
+}
+
+// CanMerge 组成相同 并且每个操作都能与对应的操作合并
+func (t *BatchCommand) CanMerge(next Command) bool {
+	cmd, ok := next.(*BatchCommand)
+	if !ok || len(cmd.commands) != len(t.commands) {
+		return false
+	}
+	for idx, c := range t.commands {
+		checker, ok := c.(mergeChecker)
+		if !ok || !checker.CanMerge(cmd.commands[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge 合并同样组成的连续批量操作 比如拖拽多个选中的控件 逐个合并对应的操作
+// 先检查所有操作都能合并 有一个不能合并时都不修改
+func (t *BatchCommand) Merge(next Command) bool {
+	if !t.CanMerge(next) {
+		return false
+	}
+	cmd := next.(*BatchCommand)
+	for idx, c := range t.commands {
+		c.Merge(cmd.commands[idx])
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+// 测试用的操作 记录目标值 同一个key的操作可以合并
+type valueCommand struct {
+	key   string
+	value *int
+	from  int
+	to    int
+}
+
+func newValueCommand(key string, value *int, to int) *valueCommand {
+	return &valueCommand{key, value, *value, to}
+}
+
+func (t *valueCommand) Do()   { *t.value = t.to }
+func (t *valueCommand) Undo() { *t.value = t.from }
+
+func (t *valueCommand) Merge(next Command) bool {
+	if !t.CanMerge(next) {
+		return false
+	}
+	t.to = next.(*valueCommand).to
+	return true
+}
+
+func (t *valueCommand) CanMerge(next Command) bool {
+	cmd, ok := next.(*valueCommand)
+	return ok && cmd.key == t.key
+}
+
+func TestBatchMerge(t *testing.T) {
+	var a, b int
+	history := NewHistory()
+	history.ExecuteMerge(NewBatchCommand(newValueCommand("a", &a, 1), newValueCommand("b", &b, 1)))
+	history.ExecuteMerge(NewBatchCommand(newValueCommand("a", &a, 2), newValueCommand("b", &b, 2)))
+	if len(history.undoStack) != 1 || a != 2 || b != 2 {
+		t.Fatalf("合并后 栈 %d a %d b %d", len(history.undoStack), a, b)
+	}
+
+	// 第二个操作不能合并 第一个也不能被修改
+	history.ExecuteMerge(NewBatchCommand(newValueCommand("a", &a, 3), newValueCommand("c", &b, 3)))
+	if len(history.undoStack) != 2 {
+		t.Fatalf("部分合并 栈 %d", len(history.undoStack))
+	}
+	history.Undo()
+	if a != 2 || b != 2 {
+		t.Fatalf("撤销一步后 a %d b %d", a, b)
+	}
+	history.Undo()
+	if a != 0 || b != 0 {
+		t.Fatalf("全部撤销后 a %d b %d", a, b)
+	}
+}
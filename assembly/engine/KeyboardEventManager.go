@@ -0,0 +1,243 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// KeyEventType 键盘事件类型
+type KeyEventType int
+
+const (
+	// KEYDOWN 键盘事件
+	KEYDOWN KeyEventType = iota
+	// KEYUP 键盘事件
+	KEYUP
+)
+
+// KeyEvent 键盘事件对象
+type KeyEvent struct {
+	target    *Box
+	eventType KeyEventType
+	key       string //KeyboardEvent.key 比如 a Z Delete ArrowLeft
+	ctrlKey   bool
+	shiftKey  bool
+	altKey    bool
+	metaKey   bool
+}
+
+// Shortcut 事件对应的快捷键 比如 Ctrl+Shift+Z
+func (t KeyEvent) Shortcut() string {
+	return formatShortcut(t.key, t.ctrlKey, t.shiftKey, t.altKey, t.metaKey)
+}
+
+// KeyHandler 键盘事件回调函数
+type KeyHandler func(evt KeyEvent)
+
+// KeyListener 键盘事件监听器
+type KeyListener struct {
+	eventType KeyEventType
+	handler   KeyHandler
+}
+
+// ShortcutsHandler 快捷键变化回调 参数为规范后的所有快捷键
+type ShortcutsHandler func(shortcuts []string)
+
+// KeyboardEventManager 键盘事件管理器
+// 按下的组合键如果注册了快捷键则只执行快捷键 否则分发给焦点控件并向父节点冒泡
+type KeyboardEventManager struct {
+	eventActionList  map[*Box][]*KeyListener
+	shortcuts        map[string]KeyHandler
+	focusBox         *Box
+	pressed          map[string]bool //当前按下的键
+	shortcutsHandler ShortcutsHandler
+}
+
+// NewKeyboardEventManager 构造函数
+func NewKeyboardEventManager() (manager *KeyboardEventManager) {
+	manager = &KeyboardEventManager{}
+	manager.eventActionList = make(map[*Box][]*KeyListener)
+	manager.shortcuts = make(map[string]KeyHandler)
+	manager.focusBox = nil
+	manager.pressed = make(map[string]bool)
+	return manager
+}
+
+// SetFocus 设置接收键盘事件的控件
+func (t *KeyboardEventManager) SetFocus(box *Box) {
+	t.focusBox = box
+}
+
+// GetFocus 获取接收键盘事件的控件
+func (t *KeyboardEventManager) GetFocus() *Box {
+	return t.focusBox
+}
+
+// IsPressed 某个键当前是否按下 key 与快捷键的按键写法相同 比如 Space Shift A
+func (t *KeyboardEventManager) IsPressed(key string) bool {
+	return t.pressed[formatShortcut(key, false, false, false, false)]
+}
+
+// ReleaseKeys 清除按键状态 窗口失去焦点时收不到keyup 需要调用
+func (t *KeyboardEventManager) ReleaseKeys() {
+	t.pressed = make(map[string]bool)
+}
+
+// BindShortcut 注册快捷键 已经注册的会被覆盖 shortcut 比如 Ctrl+Z Shift+ArrowLeft Delete
+func (t *KeyboardEventManager) BindShortcut(shortcut string, handler KeyHandler) {
+	t.shortcuts[normalizeShortcut(shortcut)] = handler
+	t.notifyShortcuts()
+}
+
+// UnbindShortcut 取消快捷键
+func (t *KeyboardEventManager) UnbindShortcut(shortcut string) {
+	delete(t.shortcuts, normalizeShortcut(shortcut))
+	t.notifyShortcuts()
+}
+
+// GetShortcuts 获取所有快捷键 规范后的形式 按字母排序
+func (t *KeyboardEventManager) GetShortcuts() []string {
+	shortcuts := make([]string, 0, len(t.shortcuts))
+	for shortcut := range t.shortcuts {
+		shortcuts = append(shortcuts, shortcut)
+	}
+	sort.Strings(shortcuts)
+	return shortcuts
+}
+
+// SetShortcutsHandler 设置快捷键变化的回调 设置时立即回调一次
+func (t *KeyboardEventManager) SetShortcutsHandler(handler ShortcutsHandler) {
+	t.shortcutsHandler = handler
+	t.notifyShortcuts()
+}
+
+func (t *KeyboardEventManager) notifyShortcuts() {
+	if t.shortcutsHandler != nil {
+		t.shortcutsHandler(t.GetShortcuts())
+	}
+}
+
+// AddEventListener 添加控件的键盘事件监听器
+func (t *KeyboardEventManager) AddEventListener(target *Box, eventType KeyEventType, callback KeyHandler) (listener *KeyListener) {
+	listener = &KeyListener{eventType, callback}
+	t.eventActionList[target] = append(t.eventActionList[target], listener)
+	return listener
+}
+
+// RemoveEventListener 删除控件的键盘事件监听器
+func (t *KeyboardEventManager) RemoveEventListener(target *Box, listener *KeyListener) {
+	list := t.eventActionList[target]
+	for idx, val := range list {
+		if val == listener {
+			t.eventActionList[target] = append(list[:idx], list[idx+1:]...)
+			return
+		}
+	}
+}
+
+// RemoveEvents 清空控件的键盘事件 控件失去焦点
+func (t *KeyboardEventManager) RemoveEvents(target *Box) {
+	delete(t.eventActionList, target)
+	if t.focusBox == target {
+		t.focusBox = nil
+	}
+}
+
+// DispatchSystemEvent 接收系统键盘事件 eventType 为 keydown keyup 返回是否执行了快捷键
+func (t *KeyboardEventManager) DispatchSystemEvent(eventType string, key string, ctrlKey, shiftKey, altKey, metaKey bool) bool {
+	evt := KeyEvent{t.focusBox, KEYDOWN, key, ctrlKey, shiftKey, altKey, metaKey}
+	if eventType == "keyup" {
+		evt.eventType = KEYUP
+	} else if eventType != "keydown" {
+		return false
+	}
+
+	name := formatShortcut(key, false, false, false, false)
+	if evt.eventType == KEYDOWN {
+		t.pressed[name] = true
+	} else {
+		delete(t.pressed, name)
+	}
+
+	if evt.eventType == KEYDOWN {
+		if handler, ok := t.shortcuts[evt.Shortcut()]; ok {
+			handler(evt)
+			return true
+		}
+	}
+
+	//从焦点控件向父节点冒泡
+	for box := t.focusBox; box != nil; box = box.parent {
+		for _, listener := range t.eventActionList[box] {
+			if listener.eventType == evt.eventType {
+				listener.handler(evt)
+			}
+		}
+		if !box.canBubble {
+			break
+		}
+	}
+	return false
+}
+
+// 把快捷键字符串规范为 Ctrl+Alt+Shift+Meta+Key 的形式 修饰键不区分大小写
+func normalizeShortcut(shortcut string) string {
+	var ctrlKey, shiftKey, altKey, metaKey bool
+	key := ""
+	parts := strings.Split(shortcut, "+")
+	for idx, part := range parts {
+		part = strings.TrimSpace(part)
+		//最后一段是按键 允许 Ctrl++ 这样的写法
+		if idx == len(parts)-1 {
+			if part == "" {
+				part = "+"
+			}
+			key = part
+			break
+		}
+		switch strings.ToLower(part) {
+		case "ctrl", "control":
+			ctrlKey = true
+		case "shift":
+			shiftKey = true
+		case "alt", "option":
+			altKey = true
+		case "meta", "cmd", "command":
+			metaKey = true
+		}
+	}
+	return formatShortcut(key, ctrlKey, shiftKey, altKey, metaKey)
+}
+
+// 按住Shift时浏览器给出的是上档字符 快捷键按美式键盘还原为下档的键 比如 Shift+1 Ctrl+Shift+]
+var shiftedKeys = map[string]string{
+	"!": "1", "@": "2", "#": "3", "$": "4", "%": "5", "^": "6", "&": "7", "*": "8", "(": "9", ")": "0",
+	"_": "-", "{": "[", "}": "]", "|": "\\", ":": ";", "\"": "'", "<": ",", ">": ".", "?": "/", "~": "`",
+}
+
+func formatShortcut(key string, ctrlKey, shiftKey, altKey, metaKey bool) string {
+	//单个字符不区分大小写 按住Shift时浏览器给出的是大写字母
+	if len([]rune(key)) == 1 {
+		key = strings.ToUpper(key)
+	}
+	if base, ok := shiftedKeys[key]; ok && shiftKey {
+		key = base
+	}
+	if key == " " {
+		key = "Space"
+	}
+	parts := make([]string, 0, 5)
+	if ctrlKey {
+		parts = append(parts, "Ctrl")
+	}
+	if altKey {
+		parts = append(parts, "Alt")
+	}
+	if shiftKey {
+		parts = append(parts, "Shift")
+	}
+	if metaKey {
+		parts = append(parts, "Meta")
+	}
+	return strings.Join(append(parts, key), "+")
+}
@@ -0,0 +1,60 @@
+package main
+
+// Marquee 框选 在空白舞台上拖拽时在交互层绘制选框
+type Marquee struct {
+	engine *Engine
+	box    *Box
+	x      int //拖拽起点 绝对坐标
+	y      int
+	active bool
+}
+
+// NewMarquee 构造函数
+func NewMarquee(engine *Engine) (marquee *Marquee) {
+	marquee = &Marquee{}
+	marquee.engine = engine
+	marquee.box = NewBox(0, 0, 0, 0, "marquee")
+	return marquee
+}
+
+// IsActive 是否正在框选
+func (t *Marquee) IsActive() bool {
+	return t.active
+}
+
+// Start 开始框选
+func (t *Marquee) Start(x, y int) {
+	t.x = x
+	t.y = y
+	t.active = true
+	t.box.x = x
+	t.box.y = y
+	t.box.width = 0
+	t.box.height = 0
+	t.engine.boxTree.AddInteractionBox(t.box, t.engine.boxTree.GetInteractionROOT())
+}
+
+// Update 拖拽到新的位置
+func (t *Marquee) Update(x, y int) {
+	if !t.active {
+		return
+	}
+	ob := t.box.GetBounds()
+	t.box.x = intMin(t.x, x)
+	t.box.y = intMin(t.y, y)
+	t.box.width = intAbs(x - t.x)
+	t.box.height = intAbs(y - t.y)
+	t.engine.render.PaintRectArea(unionBounds(ob, t.box.GetBounds(), 0))
+}
+
+// End 结束框选 返回容器中选框内的控件 contain 为true时只返回完全在选框内的控件 否则返回与选框相交的控件
+func (t *Marquee) End(container *Box, contain bool) []*Box {
+	if !t.active {
+		return nil
+	}
+	t.active = false
+	bounds := t.box.GetBounds()
+	t.engine.boxTree.RemoveInteractionBox(t.box)
+	t.engine.render.PaintBox(t.box)
+	return t.engine.boxTree.QueryMarquee(container, bounds, contain)
+}
@@ -1,10 +1,5 @@
 package main
 
-import (
-	"fmt"
-	"syscall/js"
-)
-
 // MouseEventType 鼠标事件类型
 type MouseEventType int
 
@@ -40,13 +35,32 @@ const (
 	DRAGING
 )
 
+// KeyModifiers 鼠标事件发生时按下的修饰键
+type KeyModifiers struct {
+	ctrlKey  bool
+	shiftKey bool
+	altKey   bool
+	metaKey  bool
+}
+
+// IsMultiSelect 是否按下了多选的修饰键 Shift Ctrl 或mac上的Command
+func (t KeyModifiers) IsMultiSelect() bool {
+	return t.shiftKey || t.ctrlKey || t.metaKey
+}
+
+// IsSnapDisabled 是否按下了临时关闭吸附的修饰键 Ctrl或mac上的Command
+func (t KeyModifiers) IsSnapDisabled() bool {
+	return t.ctrlKey || t.metaKey
+}
+
 // MouseEvent 鼠标事件对象
 type MouseEvent struct {
 	target    *Box
 	eventType MouseEventType
-	mouseX    int //绝对坐标
+	mouseX    int //舞台上的绝对坐标
 	mouseY    int
 	data      *Position
+	KeyModifiers
 }
 
 // EventHandler 事件回调函数
@@ -65,47 +79,111 @@ type DragStartState struct {
 	x         int
 	y         int
 	dPosition *Position
+	dragged   bool //拖拽结束后的click不分发
+}
+
+// PanController 视图平移 由引擎实现
+type PanController interface {
+	// ShouldPan 按下鼠标时是否开始平移视图 button 为按下的鼠标键
+	ShouldPan(button int) bool
+	// PanBy 平移视图 dx dy 为屏幕上的位移
+	PanBy(dx, dy int)
+}
+
+// PanState 记录平移视图的状态 坐标为屏幕坐标
+type PanState struct {
+	panning   bool
+	x         int
+	y         int
+	skipClick bool //平移结束后的click不分发
 }
 
 // MouseEventManager 鼠标事件管理器
 type MouseEventManager struct {
 	boxTree         *BoxTree
+	camera          *Camera
 	eventActionList map[*Box][]*EventListener
 	eventTopBox     *Box
 	dragState       *DragStartState
+	panController   PanController
+	panState        *PanState
+	modifiers       KeyModifiers
+	scope           *Box //进入的组合 组合中的成员可以单独响应事件
+	position        Position
 }
 
-// NewMouseEventManager 构造函数
-func NewMouseEventManager(boxTree *BoxTree) (manager *MouseEventManager) {
+// NewMouseEventManager 构造函数 camera 用于把屏幕坐标转为舞台坐标
+func NewMouseEventManager(boxTree *BoxTree, camera *Camera) (manager *MouseEventManager) {
 	manager = &MouseEventManager{}
 	manager.eventActionList = make(map[*Box][]*EventListener)
 	manager.eventTopBox = nil
 	manager.boxTree = boxTree
+	manager.camera = camera
 	manager.dragState = &DragStartState{}
+	manager.panState = &PanState{}
+	return manager
+}
 
-	//系统鼠标事件接收
-	sysMouseHandler := js.NewCallback(func(args []js.Value) {
-		//chrome or firefox
-		x := args[0].Get("offsetX").Int()
-		y := args[0].Get("offsetY").Int()
-		if x == 0 {
-			x = args[0].Get("layerX").Int()
-		}
-		if y == 0 {
-			y = args[0].Get("layerY").Int()
-		}
-		manager.dispatherEvents(args[0].Get("type").String(), x, y)
-	})
+// SetScope 设置进入的组合 其他组合（除了它的外层组合）作为整体响应事件 nil表示没有进入组合
+func (t *MouseEventManager) SetScope(group *Box) {
+	t.scope = group
+}
 
-	mainBox := js.Global().Get("document").Call("getElementById", "main-box")
-	mainBox.Call("addEventListener", "mousedown", sysMouseHandler)
-	mainBox.Call("addEventListener", "mouseup", sysMouseHandler)
-	mainBox.Call("addEventListener", "mousemove", sysMouseHandler)
-	mainBox.Call("addEventListener", "click", sysMouseHandler)
-	mainBox.Call("addEventListener", "dblclick", sysMouseHandler)
+// SetPanController 设置视图平移的控制器
+func (t *MouseEventManager) SetPanController(controller PanController) {
+	t.panController = controller
+}
 
-	// defer sysMouseHandler.Release()
-	return manager
+// DispatchSystemEvent 接收系统鼠标事件 eventType 为 mousedown mouseup mousemove click dblclick
+// x y 为屏幕坐标 button 为 MouseEvent.button 0左键 1中键 2右键
+func (t *MouseEventManager) DispatchSystemEvent(eventType string, x, y int, button int, ctrlKey, shiftKey, altKey, metaKey bool) {
+	t.modifiers = KeyModifiers{ctrlKey, shiftKey, altKey, metaKey}
+	if t.dispatchPanEvent(eventType, x, y, button) {
+		return
+	}
+	wx, wy := t.camera.ToWorld(x, y)
+	t.position = Position{wx, wy}
+	t.dispatherEvents(eventType, wx, wy)
+}
+
+// GetMousePosition 最近一次鼠标事件在舞台上的绝对坐标
+func (t *MouseEventManager) GetMousePosition() (int, int) {
+	return t.position.x, t.position.y
+}
+
+// 平移视图 返回事件是否被平移处理
+func (t *MouseEventManager) dispatchPanEvent(eventType string, x, y int, button int) bool {
+	state := t.panState
+	switch eventType {
+	case "mousedown":
+		state.skipClick = false
+		if t.panController != nil && t.panController.ShouldPan(button) {
+			state.panning = true
+			state.x = x
+			state.y = y
+			return true
+		}
+	case "mousemove":
+		if state.panning {
+			t.panController.PanBy(x-state.x, y-state.y)
+			state.x = x
+			state.y = y
+			return true
+		}
+	case "mouseup":
+		if state.panning {
+			state.panning = false
+			//中键不触发click
+			state.skipClick = button == 0
+			return true
+		}
+	case "click":
+		if state.skipClick {
+			state.skipClick = false
+			return true
+		}
+	}
+	return false
 }
 
 // AddEventListener 添加事件监听器
@@ -141,6 +219,22 @@ func (t *MouseEventManager) RemoveEvents(target *Box) {
 	delete(t.eventActionList, target)
 }
 
+// IsHovering 鼠标当前是否停留在该控件上（最上层）
+func (t *MouseEventManager) IsHovering(box *Box) bool {
+	return t.eventTopBox == box
+}
+
+// ResetBoxState 清除对控件的hover和拖拽记录 控件移出boxtree时调用
+func (t *MouseEventManager) ResetBoxState(box *Box) {
+	if t.eventTopBox == box {
+		t.eventTopBox = nil
+	}
+	if t.dragState.target == box {
+		t.dragState.target = nil
+		t.dragState.state = NONE
+	}
+}
+
 // DispatherEvents 接收系统事件 分发事件
 func (t *MouseEventManager) dispatherEvents(eventType string, mx, my int) {
 
@@ -176,11 +270,12 @@ func (t *MouseEventManager) dispatherEvents(eventType string, mx, my int) {
 		dragState.x = mx
 		dragState.y = my
 		dragState.dPosition = &Position{mx - px, my - py}
+		dragState.dragged = false
 		break
 	case "mouseup":
 		etype = MOUSEUP
-		fmt.Println("mouseup", dragState.state)
 		if dragState.state == DRAGING {
+			dragState.dragged = true
 			t.dispatchBoxEvents(dragState.target, DRAGEND, mx, my, dragState.dPosition)
 		}
 		if dragState.state != NONE {
@@ -199,6 +294,10 @@ func (t *MouseEventManager) dispatherEvents(eventType string, mx, my int) {
 		break
 	case "click":
 		etype = CLICK
+		if dragState.dragged {
+			dragState.dragged = false
+			return
+		}
 		break
 	case "dblclick":
 		etype = DBCLICK
@@ -216,31 +315,53 @@ func (t *MouseEventManager) dispatherEvents(eventType string, mx, my int) {
 
 //获取冒泡list
 func (t *MouseEventManager) getBubblingList(x, y int) []*Box {
-	boxeslist := t.boxTree.GetBoxlist()
 	list := make([]*Box, 0)
-	i := boxeslist[ROOT] //从根节点开始找
 
-	if !t.boxTree.IsPointInBox(x, y, i) {
-		return list
+	//交互层上注册了事件的控件（比如控制点）优先响应 不冒泡到控件层
+	interactionBoxeslist := t.boxTree.GetInteractionBoxeslist()
+	for k := len(interactionBoxeslist) - 1; k > ROOT; k-- {
+		box := interactionBoxeslist[k]
+		if len(t.eventActionList[box]) > 0 && t.boxTree.IsPointInBox(x, y, box) {
+			return append(list, box)
+		}
 	}
+	return t.getBoxChain(x, y)
+}
+
+// GetBoxAt 获取控件层中该点处响应鼠标事件的控件 没有进入的组合作为整体 没有命中控件时返回根节点
+func (t *MouseEventManager) GetBoxAt(x, y int) *Box {
+	chain := t.getBoxChain(x, y)
+	return chain[len(chain)-1]
+}
+
+//控件层从根节点到命中控件的冒泡list
+func (t *MouseEventManager) getBoxChain(x, y int) []*Box {
+	boxeslist := t.boxTree.GetBoxlist()
+	list := make([]*Box, 0)
+
+	i := boxeslist[ROOT] //从根节点开始找 平移或缩小后舞台外的空白也算根节点
 	list = append(list, i)
-	for {
-		children := i.children
-		//没有子节点
-		if len(children) <= 0 {
-			break
-		}
-		isChanged := false
-		for k := len(children) - 1; k >= 0; k-- {
-			if t.boxTree.IsPointInBox(x, y, children[k]) {
-				list = append(list, children[k])
-				i = children[k]
-				isChanged = true
-				break
-			}
+
+	//通过空间索引找出所有包含该点的控件
+	hits := make([]*Box, 0)
+	for _, box := range t.boxTree.QueryPoint(x, y) {
+		if t.boxTree.IsPointInBox(x, y, box) {
+			hits = append(hits, box)
 		}
-		//子节点没有冒泡
-		if !isChanged {
+	}
+	if len(hits) == 0 {
+		return list
+	}
+	//z-index最大的控件和它的所有父节点 子控件超出容器的部分也可以命中
+	t.boxTree.SortByZOrder(hits)
+	chain := make([]*Box, 0)
+	for b := hits[len(hits)-1]; b != i; b = b.parent {
+		chain = append(chain, b)
+	}
+	for k := len(chain) - 1; k >= 0; k-- {
+		list = append(list, chain[k])
+		//没有进入的组合作为整体响应 成员不响应
+		if chain[k].IsGroup() && chain[k] != t.scope && (t.scope == nil || !isAncestor(chain[k], t.scope)) {
 			break
 		}
 	}
@@ -256,7 +377,7 @@ func (t *MouseEventManager) dispatchBoxEvents(target *Box, eventType MouseEventT
 	}
 	for _, action := range t.eventActionList[target] {
 		if eventType == action.eventType {
-			action.handler(MouseEvent{target, eventType, mx, my, data})
+			action.handler(MouseEvent{target, eventType, mx, my, data, t.modifiers})
 		}
 	}
 }
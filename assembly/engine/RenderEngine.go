@@ -1,11 +1,10 @@
 package main
 
 import (
-	"fmt"
 	"image"
+	"image/png"
 	"math"
-	"syscall/js"
-	"time"
+	"os"
 
 	"github.com/fogleman/gg"
 )
@@ -22,6 +21,11 @@ type Rect struct {
 	x, y, width, height int
 }
 
+// RenderOutput 渲染输出 接收绘制好的一块区域 x y 为区域在输出目标上的坐标（缩放后）
+type RenderOutput interface {
+	Output(rgba *image.RGBA, x, y int)
+}
+
 // Viewport 渲染视口
 type Viewport struct {
 	x       int
@@ -36,100 +40,140 @@ type Viewport struct {
 type RenderEngine struct {
 	boxTree    *BoxTree
 	styleSheet *StyleSheetManager
-	vp         *Viewport
+	output     RenderOutput
+	camera     *Camera
 }
 
-// NewRenderEngine 渲染器构造函数
-func NewRenderEngine(boxTree *BoxTree, styleSheet *StyleSheetManager) (engine *RenderEngine) {
+// NewRenderEngine 渲染器构造函数 output 为 nil 时只能同步渲染成图片 相机的屏幕尺寸默认为舞台尺寸
+func NewRenderEngine(boxTree *BoxTree, styleSheet *StyleSheetManager, output RenderOutput) (engine *RenderEngine) {
 	engine = &RenderEngine{}
 	engine.boxTree = boxTree
 	engine.styleSheet = styleSheet
+	engine.output = output
+	root := boxTree.GetBoxROOT()
+	engine.camera = NewCamera(root.width, root.height)
 	return engine
 }
 
+// GetCamera 获取相机
+func (t *RenderEngine) GetCamera() *Camera {
+	return t.camera
+}
+
 // PaintBox 简易方法 绘制一个控件
-func (t *RenderEngine) PaintBox(box *Box, zoom float64) {
+func (t *RenderEngine) PaintBox(box *Box) {
 	bounds := box.GetBounds()
 	// fmt.Println(bounds)
-	go t.PaintRectArea(&Rect{bounds.x, bounds.y, bounds.width, bounds.height}, zoom)
+	t.PaintRectArea(&Rect{bounds.x, bounds.y, bounds.width, bounds.height})
 }
 
-// PaintRectArea 绘制一个区域
-func (t *RenderEngine) PaintRectArea(rect *Rect, zoom float64) {
-	go t.paintRect(rect, zoom)
+// PaintAll 重绘整个屏幕 相机变化后调用
+func (t *RenderEngine) PaintAll() {
+	t.PaintRectArea(t.camera.VisibleRect())
 }
 
-// PaintToScreen 绘制到屏幕
-func (t *RenderEngine) PaintToScreen() {
-	//绘制
-	tvp := t.vp
-	c := tvp.context
-	rgba := c.Image().(*image.RGBA)
-	width := c.Width()
-	height := c.Height()
-
-	print := js.NewCallback(func(args []js.Value) {
-		if width > 0 && height > 0 {
-			js.Global().Get("window").Call("printer", js.TypedArrayOf(rgba.Pix), js.ValueOf(tvp.x), js.ValueOf(tvp.y), js.ValueOf(width), js.ValueOf(height))
-		}
-	})
-	js.Global().Call("requestAnimationFrame", print)
+// PaintRectArea 绘制一个区域 矩形是舞台坐标 只绘制屏幕上可见的部分
+func (t *RenderEngine) PaintRectArea(rect *Rect) {
+	//记录当前的相机 绘制过程中相机变化不影响这次绘制
+	camera := *t.camera
+	go t.paintRect(rect, camera)
+}
+
+// RenderImage 同步绘制一个矩形区域的控件层（不含交互层） 矩形是缩放前的坐标
+func (t *RenderEngine) RenderImage(rect *Rect, zoom float64) *image.RGBA {
+	vp := t.rasterize(rect, Camera{zoom: zoom}, false)
+	return vp.context.Image().(*image.RGBA)
+}
+
+// SavePNG 把一个矩形区域的控件层保存为png文件
+func (t *RenderEngine) SavePNG(path string, rect *Rect, zoom float64) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, t.RenderImage(rect, zoom)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
 
+// 绘制一个矩形区域并输出到屏幕 矩形是舞台坐标
+func (t *RenderEngine) paintRect(rect *Rect, camera Camera) {
+	if t.output == nil {
+		return
+	}
+	//只绘制与屏幕的交集
+	rect = clipRect(rect, camera.VisibleRect())
+	if rect.width <= 0 || rect.height <= 0 {
+		return
+	}
+	//填写viewport数据
+	vp := t.rasterize(rect, camera, true)
+	//输出
+	if vp.width > 0 && vp.height > 0 {
+		t.output.Output(vp.context.Image().(*image.RGBA), vp.x, vp.y)
+	}
 }
 
-// PaintRectArea 绘制一个矩形区域 矩形是缩放前的坐标
-func (t *RenderEngine) paintRect(rect *Rect, zoom float64) {
-	t.vp = &Viewport{}
-	vp := t.vp
-	vp.x = round(math.Ceil(float64(rect.x) * zoom))
-	vp.y = round(math.Ceil(float64(rect.y) * zoom))
-	vp.width = round(math.Ceil(float64(rect.width) * zoom))
-	vp.height = round(math.Ceil(float64(rect.height) * zoom))
+// 通过相机把一个舞台区域光栅化到新的viewport viewport的坐标是屏幕坐标 控件按舞台坐标绘制
+func (t *RenderEngine) rasterize(rect *Rect, camera Camera, interaction bool) *Viewport {
+	zoom := camera.zoom
+	vp := &Viewport{}
+	vp.x = int(math.Floor((float64(rect.x) - camera.x) * zoom))
+	vp.y = int(math.Floor((float64(rect.y) - camera.y) * zoom))
+	vp.width = intMax(int(math.Ceil((float64(rect.x+rect.width)-camera.x)*zoom))-vp.x, 0)
+	vp.height = intMax(int(math.Ceil((float64(rect.y+rect.height)-camera.y)*zoom))-vp.y, 0)
 	vp.zoom = zoom
 	vp.context = gg.NewContext(vp.width, vp.height)
-	tm := time.Now()
-	//填写viewport数据
-	t.paintViewport()
-	//绘制到屏幕
-	t.PaintToScreen()
-	fmt.Println("渲染数据准备：", time.Now().Sub(tm))
+	vp.context.Translate(-float64(vp.x), -float64(vp.y))
+	vp.context.Scale(zoom, zoom)
+	vp.context.Translate(-camera.x, -camera.y)
+	t.paintViewport(vp, rect, interaction)
+	return vp
 }
 
-// 绘制一个 视口
-func (t *RenderEngine) paintViewport() {
+// 绘制一个 视口 rect 为缩放前的区域
+func (t *RenderEngine) paintViewport(vp *Viewport, rect *Rect, interaction bool) {
 	boxeslist := t.boxTree.GetBoxlist()
 	interactionBoxeslist := t.boxTree.GetInteractionBoxeslist()
+	// 舞台背景 当前主题的stage颜色
+	if stage := t.styleSheet.GetThemeColor("stage"); stage != nil {
+		vp.context.SetColor(stage)
+		vp.context.DrawRectangle(float64(rect.x), float64(rect.y), float64(rect.width), float64(rect.height))
+		vp.context.Fill()
+	}
 	if len(boxeslist) > 1 {
-		// 绘制控件
-		t.renderBox(boxeslist[ROOT], BOX)
+		// 绘制控件 只绘制与区域相交的控件
+		t.renderBoxesInRect(vp, rect)
 	}
-	if len(interactionBoxeslist) > 1 {
+	if interaction && len(interactionBoxeslist) > 1 {
 		// 绘制交互层
-		t.renderBox(interactionBoxeslist[ROOT], INTERACTION)
+		t.renderBox(vp, interactionBoxeslist[ROOT], INTERACTION)
 	}
 }
 
-//绘制一个容器里的控件
-func (t *RenderEngine) renderBoxesInContainer(container *Box, layer int) {
-
-	var list []*Box
-	if layer == BOX {
-		list = t.boxTree.GetBoxlist()
-	} else if layer == INTERACTION {
-		list = t.boxTree.GetInteractionBoxeslist()
+//通过空间索引找出区域内的控件 按照z-index顺序绘制
+func (t *RenderEngine) renderBoxesInRect(vp *Viewport, rect *Rect) {
+	list := t.boxTree.QueryRect(Bounds{rect.x, rect.y, rect.width, rect.height})
+	t.boxTree.SortByZOrder(list)
+	for _, box := range list {
+		//不可用的控件以及它的子控件不渲染
+		if isBoxUsed(box) {
+			t.drawBox(vp, box)
+		}
 	}
+}
 
-	for _, v := range list {
-		if v.parent == container {
-			t.renderBox(v, layer)
-		} else {
-			continue
-		}
+//绘制一个容器里的控件
+func (t *RenderEngine) renderBoxesInContainer(vp *Viewport, container *Box, layer int) {
+	for _, v := range container.children {
+		t.renderBox(vp, v, layer)
 	}
 }
 
 //绘制控件
-func (t *RenderEngine) renderBox(box *Box, layer int) {
+func (t *RenderEngine) renderBox(vp *Viewport, box *Box, layer int) {
 
 	var list []*Box
 	if layer == BOX {
@@ -140,48 +184,71 @@ func (t *RenderEngine) renderBox(box *Box, layer int) {
 
 	//如果是 根节点 直接绘制子节点
 	if box == list[ROOT] {
-		t.renderBoxesInContainer(box, layer)
+		t.renderBoxesInContainer(vp, box, layer)
 		return
 	}
 
-	//选中的控件或者不可用的控件不渲染
-	if !box.isUsed || box.isSelected {
+	//不可用的控件不渲染 选中的控件照常渲染 选中框在交互层绘制
+	if !box.isUsed {
 		return
 	}
 
 	//绘制
-	t.drawBox(box)
+	t.drawBox(vp, box)
 
 	//递归 如果此box是容器，继续绘制里面的元素
-	t.renderBoxesInContainer(box, layer)
+	t.renderBoxesInContainer(vp, box, layer)
 }
 
-// 按照物理尺寸填充一个矩形区域
-func (t *RenderEngine) drawBox(box *Box) {
-	style := t.styleSheet.GetStyle(box.styleClass)
+// 按照缩放前的坐标绘制一个控件 viewport的context已经包含了平移和缩放
+// 控件在自身的局部坐标系中绘制 局部坐标系包含所有父节点的平移和旋转
+// 透明度作用于填充色和边框色的alpha 圆角同时作用于填充和边框
+func (t *RenderEngine) drawBox(vp *Viewport, box *Box) {
+	style := t.styleSheet.ComputeStyle(box)
+	if style.opacity <= 0 {
+		return
+	}
 
-	x := box.x - t.vp.x
-	y := box.y - t.vp.y
-	// cx, cy := box.GetCenterPoint()
-	cx, cy := box.width/2+box.x, box.height/2+box.y
-	cx -= t.vp.x
-	cy -= t.vp.y
+	w := float64(box.width)
+	h := float64(box.height)
+	radius := math.Min(float64(style.radius), math.Min(w, h)/2)
 
-	// fmt.Println(cx, cy)
-	context := t.vp.context
+	context := vp.context
 	context.Push()
-	// context.RotateAbout(math.Pi/4, 100, 100)
-	context.SetFillStyle(gg.NewSolidPattern(style.backgroundColor))
-	context.RotateAbout(box.angle, float64(cx), float64(cy))
-	context.DrawRectangle(float64(x), float64(y), float64(box.width), float64(box.height))
-	context.Fill()
+	//变换只包含平移和旋转
+	m := box.GetTransform()
+	context.Translate(m.e, m.f)
+	context.Rotate(m.Angle())
+	if !style.bgTransparent && style.backgroundColor != nil {
+		context.SetFillStyle(gg.NewSolidPattern(applyOpacity(style.backgroundColor, style.opacity)))
+		drawRectangle(context, 0, 0, w, h, radius)
+		context.Fill()
+	}
+	// 边框向内收缩半个线宽 保证不超出控件的bounds 局部重绘时不留残影
+	if style.borderWeight > 0 && style.borderColor != nil {
+		lw := float64(style.borderWeight)
+		context.SetStrokeStyle(gg.NewSolidPattern(applyOpacity(style.borderColor, style.opacity)))
+		context.SetLineWidth(lw)
+		context.SetDash(style.dash...)
+		drawRectangle(context, lw/2, lw/2, w-lw, h-lw, math.Max(radius-lw/2, 0))
+		context.Stroke()
+	}
 	context.Pop()
 }
 
+// 矩形路径 radius大于0时为圆角矩形
+func drawRectangle(context *gg.Context, x, y, w, h, radius float64) {
+	if radius > 0 {
+		context.DrawRoundedRectangle(x, y, w, h, radius)
+	} else {
+		context.DrawRectangle(x, y, w, h)
+	}
+}
+
 //获取跟视口的交集
-func (t *RenderEngine) intersectionRect(rect1 Rect) Rect {
+func (t *RenderEngine) intersectionRect(vp *Viewport, rect1 Rect) Rect {
 	var r Rect
-	viewport := t.vp
+	viewport := vp
 	r.x = intMax(rect1.x, viewport.x)
 	r.y = intMax(rect1.y, viewport.y)
 
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "重新生成testdata中的基准图片")
+
+// 基准图片中每个通道允许的误差 抗锯齿的边缘在不同平台上可能有细微差别
+const GOLDENTOLERANCE = 2
+
+// 每种样式属性一张基准图片 底下的.base控件用于检查透明和不透明度
+var goldenCases = []struct {
+	name  string
+	style string
+	angle float64
+}{
+	{"border-weight", "background: #3366cc; border: 6px solid #202020;", 0},
+	{"border-none", "background: #3366cc; border: none;", 0},
+	{"transparent", "background: transparent; border: 2px solid #202020;", 0},
+	{"opacity", "background: #3366cc; border: 4px solid #202020; opacity: 0.5;", 0},
+	{"dashed", "background: transparent; border: 2px dashed #202020;", 0},
+	{"dotted", "background: transparent; border: 2px dotted #202020;", 0},
+	{"border-dash", "background: transparent; border: 2px solid #202020; border-dash: 8 2 2 2;", 0},
+	{"radius", "background: #3366cc; border: 2px solid #202020; border-radius: 10px;", 0},
+	{"radius-rotated", "background: #3366cc; border: 4px solid #202020; border-radius: 10px;", math.Pi / 6},
+}
+
+func renderGolden(t *testing.T, style string, angle float64) *image.RGBA {
+	tree := NewBoxTree(80, 60)
+	styleSheet := NewStyleSheetManager()
+	if err := styleSheet.LoadStyleSheet(".base { background: #ffcc00; border: none; } .t { " + style + " }"); err != nil {
+		t.Fatal(err)
+	}
+	tree.AddBox(NewBox(0, 30, 80, 30, "base"), tree.GetBoxROOT())
+	box := NewBox(12, 10, 56, 40, "t")
+	box.angle = angle
+	tree.AddBox(box, tree.GetBoxROOT())
+	render := NewRenderEngine(tree, styleSheet, nil)
+	return render.RenderImage(&Rect{0, 0, 80, 60}, 1)
+}
+
+func TestRenderGolden(t *testing.T) {
+	for _, c := range goldenCases {
+		got := renderGolden(t, c.style, c.angle)
+		path := filepath.Join("testdata", "render-"+c.name+".png")
+		if *updateGolden {
+			if err := writePNG(path, got); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := readPNG(path)
+		if err != nil {
+			t.Fatalf("%s: %v (用 -update 生成基准图片)", c.name, err)
+		}
+		if n := countDiffPixels(got, want); n != 0 {
+			t.Errorf("%s: %d 个像素与 %s 不同", c.name, n, path)
+		}
+	}
+}
+
+func readPNG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return png.Decode(file)
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// 比较两张图片 返回误差超过GOLDENTOLERANCE的像素数 尺寸不同时所有像素都算不同
+func countDiffPixels(got *image.RGBA, want image.Image) int {
+	bounds := got.Bounds()
+	if want.Bounds().Size() != bounds.Size() {
+		return bounds.Dx() * bounds.Dy()
+	}
+	offset := want.Bounds().Min.Sub(bounds.Min)
+	count := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r1, g1, b1, a1 := got.At(x, y).RGBA()
+			r2, g2, b2, a2 := want.At(x+offset.X, y+offset.Y).RGBA()
+			for _, d := range []int{int(r1>>8) - int(r2>>8), int(g1>>8) - int(g2>>8), int(b1>>8) - int(b2>>8), int(a1>>8) - int(a2>>8)} {
+				if d > GOLDENTOLERANCE || d < -GOLDENTOLERANCE {
+					count++
+					break
+				}
+			}
+		}
+	}
+	return count
+}
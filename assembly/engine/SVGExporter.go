@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SVGOptions SVG导出选项
+type SVGOptions struct {
+	Attributes bool // 为控件输出 id data-name 和 class 属性
+	Background bool // 用主题的stage颜色绘制背景
+}
+
+// SVGExporter 把boxtree导出为SVG 控件按z-index顺序输出 每个控件是一个<g> 子控件嵌套在父控件的<g>中
+// 控件坐标相对父节点 旋转以控件中心为原点 与渲染器一致
+type SVGExporter struct {
+	boxTree    *BoxTree
+	styleSheet *StyleSheetManager
+	options    SVGOptions
+}
+
+// NewSVGExporter 构造函数
+func NewSVGExporter(boxTree *BoxTree, styleSheet *StyleSheetManager, options SVGOptions) (exporter *SVGExporter) {
+	exporter = &SVGExporter{}
+	exporter.boxTree = boxTree
+	exporter.styleSheet = styleSheet
+	exporter.options = options
+	return exporter
+}
+
+// ExportStage 导出整个舞台
+func (t *SVGExporter) ExportStage() []byte {
+	root := t.boxTree.GetBoxROOT()
+	buf := &bytes.Buffer{}
+	t.writeHeader(buf, Bounds{root.x, root.y, root.width, root.height})
+	for _, box := range root.children {
+		t.writeBox(buf, box, 1)
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// ExportBoxes 导出一组控件 画布为这些控件（连同子控件）外框的并集 控件按绝对位置输出
+// boxes 中不能同时包含父控件和它的子控件
+func (t *SVGExporter) ExportBoxes(boxes []*Box) []byte {
+	list := append([]*Box{}, boxes...)
+	t.boxTree.SortByZOrder(list)
+	var bounds Bounds
+	for idx, box := range list {
+		b := t.boxTree.GetSubtreeBounds(box)
+		if idx == 0 {
+			bounds = b
+		} else {
+			r := unionBounds(bounds, b, 0)
+			bounds = Bounds{r.x, r.y, r.width, r.height}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	t.writeHeader(buf, bounds)
+	for _, box := range list {
+		if box.parent != nil && box.parent.parent != nil {
+			//父控件的变换 子控件的坐标相对父控件
+			fmt.Fprintf(buf, "  <g transform=\"%s\">\n", formatSVGMatrix(box.parent.GetTransform()))
+			t.writeBox(buf, box, 2)
+			buf.WriteString("  </g>\n")
+		} else {
+			t.writeBox(buf, box, 1)
+		}
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+func (t *SVGExporter) writeHeader(buf *bytes.Buffer, bounds Bounds) {
+	buf.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(buf, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"%d %d %d %d\">\n",
+		bounds.width, bounds.height, bounds.x, bounds.y, bounds.width, bounds.height)
+	if t.options.Background {
+		if stage := t.styleSheet.GetThemeColor("stage"); stage != nil {
+			fmt.Fprintf(buf, "  <rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\"%s/>\n",
+				bounds.x, bounds.y, bounds.width, bounds.height, formatSVGPaint("fill", stage, 1))
+		}
+	}
+}
+
+// 输出一个控件和它的子控件 不可用的控件连同子控件不输出
+func (t *SVGExporter) writeBox(buf *bytes.Buffer, box *Box, depth int) {
+	if !box.isUsed {
+		return
+	}
+	indent := strings.Repeat("  ", depth)
+	buf.WriteString(indent + "<g")
+	if t.options.Attributes {
+		writeSVGAttr(buf, "id", t.boxID(box))
+		if box.name != "" {
+			writeSVGAttr(buf, "data-name", box.name)
+		}
+		if box.styleClass != "" {
+			writeSVGAttr(buf, "class", strings.Join(box.GetClasses(), " "))
+		}
+	}
+	transform := "translate(" + formatSVGNumber(float64(box.x)) + " " + formatSVGNumber(float64(box.y)) + ")"
+	if box.angle != 0 {
+		transform += " rotate(" + formatSVGNumber(box.angle*180/math.Pi) + " " +
+			formatSVGNumber(float64(box.width)/2) + " " + formatSVGNumber(float64(box.height)/2) + ")"
+	}
+	writeSVGAttr(buf, "transform", transform)
+	buf.WriteString(">\n")
+
+	t.writeRect(buf, box, indent+"  ")
+	for _, child := range box.children {
+		t.writeBox(buf, child, depth+1)
+	}
+	buf.WriteString(indent + "</g>\n")
+}
+
+// 按计算样式输出控件的矩形 填充和边框分开输出 边框向内收缩半个线宽 与渲染器一致
+func (t *SVGExporter) writeRect(buf *bytes.Buffer, box *Box, indent string) {
+	style := t.styleSheet.ComputeStyle(box)
+	if style.opacity <= 0 {
+		return
+	}
+	w := float64(box.width)
+	h := float64(box.height)
+	radius := math.Min(float64(style.radius), math.Min(w, h)/2)
+
+	if !style.bgTransparent && style.backgroundColor != nil {
+		buf.WriteString(indent)
+		writeSVGRect(buf, 0, 0, w, h, radius)
+		buf.WriteString(formatSVGPaint("fill", style.backgroundColor, style.opacity))
+		buf.WriteString("/>\n")
+	}
+	if style.borderWeight > 0 && style.borderColor != nil {
+		lw := float64(style.borderWeight)
+		buf.WriteString(indent)
+		writeSVGRect(buf, lw/2, lw/2, w-lw, h-lw, math.Max(radius-lw/2, 0))
+		buf.WriteString(" fill=\"none\"")
+		buf.WriteString(formatSVGPaint("stroke", style.borderColor, style.opacity))
+		writeSVGAttr(buf, "stroke-width", formatSVGNumber(lw))
+		if len(style.dash) > 0 {
+			dash := make([]string, len(style.dash))
+			for idx, v := range style.dash {
+				dash[idx] = formatSVGNumber(v)
+			}
+			writeSVGAttr(buf, "stroke-dasharray", strings.Join(dash, " "))
+		}
+		buf.WriteString("/>\n")
+	}
+}
+
+// 控件的id 与文档中的id对应 加前缀避免以数字开头
+func (t *SVGExporter) boxID(box *Box) string {
+	return "box-" + box.id
+}
+
+func writeSVGRect(buf *bytes.Buffer, x, y, w, h, radius float64) {
+	buf.WriteString("<rect")
+	writeSVGAttr(buf, "x", formatSVGNumber(x))
+	writeSVGAttr(buf, "y", formatSVGNumber(y))
+	writeSVGAttr(buf, "width", formatSVGNumber(w))
+	writeSVGAttr(buf, "height", formatSVGNumber(h))
+	if radius > 0 {
+		writeSVGAttr(buf, "rx", formatSVGNumber(radius))
+	}
+}
+
+func writeSVGAttr(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(" " + name + "=\"")
+	xml.EscapeText(buf, []byte(value))
+	buf.WriteString("\"")
+}
+
+// 颜色转为 fill="#rrggbb" fill-opacity="a" 透明度为颜色的alpha乘以样式的opacity
+func formatSVGPaint(name string, c color.Color, opacity float64) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	s := fmt.Sprintf(" %s=\"#%02x%02x%02x\"", name, n.R, n.G, n.B)
+	alpha := float64(n.A) / 255 * math.Min(math.Max(opacity, 0), 1)
+	if alpha < 1 {
+		s += fmt.Sprintf(" %s-opacity=\"%s\"", name, formatSVGNumber(alpha))
+	}
+	return s
+}
+
+// 变换矩阵转为 matrix(a b c d e f)
+func formatSVGMatrix(m Matrix) string {
+	values := []float64{m.a, m.b, m.c, m.d, m.e, m.f}
+	parts := make([]string, len(values))
+	for idx, v := range values {
+		parts[idx] = formatSVGNumber(v)
+	}
+	return "matrix(" + strings.Join(parts, " ") + ")"
+}
+
+// 数字最多保留4位小数
+func formatSVGNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10000)/10000+0, 'f', -1, 64) //加0去掉负零
+}
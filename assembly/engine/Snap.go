@@ -0,0 +1,329 @@
+package main
+
+import "math"
+
+// SNAPTOLERANCE 默认的吸附距离 屏幕像素 与缩放无关
+const SNAPTOLERANCE = 6
+
+// snapRect 吸附计算用的矩形 绝对坐标
+type snapRect struct {
+	minX, minY float64
+	maxX, maxY float64
+}
+
+// 多边形的外框
+func pointsRect(points []Point) snapRect {
+	rect := snapRect{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range points {
+		rect.minX = math.Min(rect.minX, p.x)
+		rect.minY = math.Min(rect.minY, p.y)
+		rect.maxX = math.Max(rect.maxX, p.x)
+		rect.maxY = math.Max(rect.maxY, p.y)
+	}
+	return rect
+}
+
+// 一组控件旋转后外框的并集
+func boxesRect(boxes []*Box) snapRect {
+	points := make([]Point, 0, len(boxes)*4)
+	for _, box := range boxes {
+		points = append(points, box.GetCorners()...)
+	}
+	return pointsRect(points)
+}
+
+// snapLine 吸附的候选线 value 为竖线的x或横线的y from to 为线所在控件在另一个方向上的范围 用于绘制对齐线
+type snapLine struct {
+	value    float64
+	from, to float64
+}
+
+// Guide 参考线 vertical 为true时是x=position的竖线 否则是y=position的横线
+type Guide struct {
+	vertical bool
+	position int
+	box      *Box //交互层的线
+}
+
+// Vertical 是否是竖线
+func (t *Guide) Vertical() bool {
+	return t.vertical
+}
+
+// Position 参考线的位置
+func (t *Guide) Position() int {
+	return t.position
+}
+
+// Snapper 吸附 平移 拉伸和旋转时把控件吸附到其他控件的边和中心 参考线和网格
+// 开始拖拽时收集屏幕上其他控件的候选线 拖拽时在交互层显示对齐线
+type Snapper struct {
+	engine *Engine
+	xs     []snapLine //竖线候选
+	ys     []snapLine //横线候选
+	angles []float64  //其他控件的绝对角度
+	lines  []*Box     //正在显示的对齐线
+	guides []*Guide
+}
+
+// NewSnapper 构造函数
+func NewSnapper(engine *Engine) (snapper *Snapper) {
+	snapper = &Snapper{}
+	snapper.engine = engine
+	snapper.lines = make([]*Box, 0)
+	snapper.guides = make([]*Guide, 0)
+	return snapper
+}
+
+// Begin 开始一次拖拽 收集屏幕上除了 boxes（连同子控件）之外的控件的边和中心 舞台的边和中心 以及参考线
+func (t *Snapper) Begin(boxes []*Box) {
+	t.xs = t.xs[:0]
+	t.ys = t.ys[:0]
+	t.angles = t.angles[:0]
+	excluded := make(map[*Box]bool)
+	for _, box := range boxes {
+		excluded[box] = true
+		for _, b := range getDescendants(box) {
+			excluded[b] = true
+		}
+	}
+	if t.engine.config.snapToBoxes {
+		root := t.engine.boxTree.GetBoxROOT()
+		t.addRect(snapRect{float64(root.x), float64(root.y), float64(root.x + root.width), float64(root.y + root.height)})
+		visible := t.engine.render.GetCamera().VisibleRect()
+		for _, box := range t.engine.boxTree.QueryRect(Bounds{visible.x, visible.y, visible.width, visible.height}) {
+			if excluded[box] || !isBoxUsed(box) {
+				continue
+			}
+			t.addRect(pointsRect(box.GetCorners()))
+			t.angles = append(t.angles, box.GetWorldAngle())
+		}
+	}
+	for _, guide := range t.guides {
+		//参考线本身已经显示 对齐线只画在拖拽的控件上
+		line := snapLine{float64(guide.position), math.Inf(1), math.Inf(-1)}
+		if guide.vertical {
+			t.xs = append(t.xs, line)
+		} else {
+			t.ys = append(t.ys, line)
+		}
+	}
+}
+
+// End 结束拖拽 清除对齐线
+func (t *Snapper) End() {
+	t.showLines(nil, nil, snapRect{})
+}
+
+// SnapMove 吸附平移 rect 为平移前控件的外框 dx dy 为鼠标的位移 返回吸附后的位移
+// 控件的左中右和上中下优先吸附到吸附范围内最近的候选线 没有时左上角吸附到网格 按住Ctrl或Command时不吸附
+func (t *Snapper) SnapMove(rect snapRect, dx, dy float64, modifiers KeyModifiers) (float64, float64) {
+	if modifiers.IsSnapDisabled() {
+		t.End()
+		return dx, dy
+	}
+	xs := []float64{rect.minX + dx, (rect.minX+rect.maxX)/2 + dx, rect.maxX + dx}
+	ys := []float64{rect.minY + dy, (rect.minY+rect.maxY)/2 + dy, rect.maxY + dy}
+	ox, linesX := t.snapAxis(xs, t.xs)
+	oy, linesY := t.snapAxis(ys, t.ys)
+	dx += ox
+	dy += oy
+	t.showLines(linesX, linesY, snapRect{rect.minX + dx, rect.minY + dy, rect.maxX + dx, rect.maxY + dy})
+	return dx, dy
+}
+
+// SnapPoint 吸附一个点 比如拉伸时的控制点 snapX snapY 为是否吸附x和y 按住Ctrl或Command时不吸附
+func (t *Snapper) SnapPoint(x, y float64, snapX, snapY bool, modifiers KeyModifiers) (float64, float64) {
+	if modifiers.IsSnapDisabled() {
+		t.End()
+		return x, y
+	}
+	var linesX, linesY []snapLine
+	if snapX {
+		var ox float64
+		ox, linesX = t.snapAxis([]float64{x}, t.xs)
+		x += ox
+	}
+	if snapY {
+		var oy float64
+		oy, linesY = t.snapAxis([]float64{y}, t.ys)
+		y += oy
+	}
+	t.showLines(linesX, linesY, snapRect{x, y, x, y})
+	return x, y
+}
+
+// SnapAngle 吸附旋转角度 angle 为相对父节点的角度 parentAngle 为父节点的绝对角度 radius 为控制点到中心的距离
+// 设置了旋转吸附的增量时按增量吸附 否则绝对角度吸附到水平竖直和其他控件的角度 按住Ctrl或Command时不吸附
+func (t *Snapper) SnapAngle(angle, parentAngle, radius float64, modifiers KeyModifiers) float64 {
+	if modifiers.IsSnapDisabled() {
+		return angle
+	}
+	if snap := t.engine.config.rotateSnap; snap > 0 {
+		return math.Floor(angle/snap+0.5) * snap
+	}
+	if !t.engine.config.snapToBoxes {
+		return angle
+	}
+	//吸附范围换算为控制点所在圆上的弧度
+	tolerance := t.tolerance() / math.Max(radius, 1)
+	world := angle + parentAngle
+	best := math.Inf(1)
+	candidates := append([]float64{0, math.Pi / 2, math.Pi, math.Pi * 3 / 2}, t.angles...)
+	for _, c := range candidates {
+		diff := math.Remainder(c-world, 2*math.Pi)
+		if math.Abs(diff) <= tolerance && math.Abs(diff) < math.Abs(best) {
+			best = diff
+		}
+	}
+	if math.IsInf(best, 1) {
+		return angle
+	}
+	return angle + best
+}
+
+// 一个方向上的吸附 values 为控件上可以吸附的位置 返回偏移量和对齐的候选线
+// 候选线优先 都不在吸附范围内时第一个位置吸附到网格
+func (t *Snapper) snapAxis(values []float64, candidates []snapLine) (float64, []snapLine) {
+	tolerance := t.tolerance()
+	offset := math.Inf(1)
+	for _, line := range candidates {
+		for _, v := range values {
+			d := line.value - v
+			if math.Abs(d) <= tolerance && math.Abs(d) < math.Abs(offset) {
+				offset = d
+			}
+		}
+	}
+	if math.IsInf(offset, 1) {
+		if grid := float64(t.engine.config.snapGrid); grid > 0 {
+			return math.Round(values[0]/grid)*grid - values[0], nil
+		}
+		return 0, nil
+	}
+	matched := make([]snapLine, 0)
+	for _, line := range candidates {
+		for _, v := range values {
+			if math.Abs(line.value-v-offset) < 0.01 {
+				matched = append(matched, line)
+				break
+			}
+		}
+	}
+	return offset, matched
+}
+
+// 吸附范围 屏幕像素换算为舞台坐标
+func (t *Snapper) tolerance() float64 {
+	return float64(t.engine.config.snapTolerance) / t.engine.render.GetCamera().GetZoom()
+}
+
+// 线宽 屏幕上一个像素
+func (t *Snapper) lineWidth() int {
+	return intMax(round(1/t.engine.render.GetCamera().GetZoom()), 1)
+}
+
+func (t *Snapper) addRect(rect snapRect) {
+	t.xs = append(t.xs,
+		snapLine{rect.minX, rect.minY, rect.maxY},
+		snapLine{(rect.minX + rect.maxX) / 2, rect.minY, rect.maxY},
+		snapLine{rect.maxX, rect.minY, rect.maxY})
+	t.ys = append(t.ys,
+		snapLine{rect.minY, rect.minX, rect.maxX},
+		snapLine{(rect.minY + rect.maxY) / 2, rect.minX, rect.maxX},
+		snapLine{rect.maxY, rect.minX, rect.maxX})
+}
+
+// 在交互层显示对齐线 同一位置的候选线合并 对齐线从拖拽的控件延伸到对齐的控件
+func (t *Snapper) showLines(linesX, linesY []snapLine, rect snapRect) {
+	interactionRoot := t.engine.boxTree.GetInteractionROOT()
+	for _, line := range t.lines {
+		t.engine.boxTree.RemoveInteractionBox(line)
+		t.engine.render.PaintBox(line)
+	}
+	t.lines = t.lines[:0]
+	w := t.lineWidth()
+	for _, line := range mergeSnapLines(linesX) {
+		from := math.Min(line.from, rect.minY)
+		to := math.Max(line.to, rect.maxY)
+		box := NewBox(round(line.value)-w/2, round(from), w, intMax(round(to-from), 1), "snapline")
+		t.lines = append(t.lines, box)
+	}
+	for _, line := range mergeSnapLines(linesY) {
+		from := math.Min(line.from, rect.minX)
+		to := math.Max(line.to, rect.maxX)
+		box := NewBox(round(from), round(line.value)-w/2, intMax(round(to-from), 1), w, "snapline")
+		t.lines = append(t.lines, box)
+	}
+	for _, box := range t.lines {
+		t.engine.boxTree.AddInteractionBox(box, interactionRoot)
+		t.engine.render.PaintBox(box)
+	}
+}
+
+// 合并同一位置的候选线 范围取并集
+func mergeSnapLines(lines []snapLine) []snapLine {
+	merged := make([]snapLine, 0, len(lines))
+	for _, line := range lines {
+		found := false
+		for idx := range merged {
+			if math.Abs(merged[idx].value-line.value) < 0.5 {
+				merged[idx].from = math.Min(merged[idx].from, line.from)
+				merged[idx].to = math.Max(merged[idx].to, line.to)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, line)
+		}
+	}
+	return merged
+}
+
+// AddGuide 添加参考线 参考线横跨整个舞台 显示在交互层
+func (t *Snapper) AddGuide(vertical bool, position int) *Guide {
+	guide := &Guide{vertical, position, NewBox(0, 0, 0, 0, "guide")}
+	t.guides = append(t.guides, guide)
+	t.layoutGuide(guide)
+	t.engine.boxTree.AddInteractionBox(guide.box, t.engine.boxTree.GetInteractionROOT())
+	t.engine.render.PaintBox(guide.box)
+	return guide
+}
+
+// RemoveGuide 删除参考线
+func (t *Snapper) RemoveGuide(guide *Guide) {
+	for idx, g := range t.guides {
+		if g == guide {
+			t.guides = append(t.guides[:idx], t.guides[idx+1:]...)
+			t.engine.boxTree.RemoveInteractionBox(guide.box)
+			t.engine.render.PaintBox(guide.box)
+			return
+		}
+	}
+}
+
+// GetGuides 获取所有参考线
+func (t *Snapper) GetGuides() []*Guide {
+	guides := make([]*Guide, len(t.guides))
+	copy(guides, t.guides)
+	return guides
+}
+
+// RefreshGuides 缩放变化后重新计算参考线的线宽 保持屏幕上一个像素
+func (t *Snapper) RefreshGuides() {
+	for _, guide := range t.guides {
+		t.layoutGuide(guide)
+	}
+}
+
+func (t *Snapper) layoutGuide(guide *Guide) {
+	root := t.engine.boxTree.GetBoxROOT()
+	w := t.lineWidth()
+	box := guide.box
+	if guide.vertical {
+		box.x, box.y, box.width, box.height = guide.position-w/2, root.y, w, root.height
+	} else {
+		box.x, box.y, box.width, box.height = root.x, guide.position-w/2, root.width, w
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+const (
+	// QUADTREECAPACITY 节点中的控件数超过该值时分裂
+	QUADTREECAPACITY = 16
+	// QUADTREEMAXDEEP 四叉树的最大深度
+	QUADTREEMAXDEEP = 12
+)
+
+// quadNode 四叉树节点 items 为无法完整放入某个子节点的控件
+type quadNode struct {
+	bounds   Bounds
+	deep     int
+	items    []*Box
+	children []*quadNode
+}
+
+// SpatialIndex 控件的空间索引 四叉树 按控件的绝对bounds索引
+// 控件超出根节点范围时根节点向外扩大一倍 原来的根节点成为新根节点的一个子节点
+type SpatialIndex struct {
+	root   *quadNode
+	nodes  map[*Box]*quadNode //控件所在的节点
+	bounds map[*Box]Bounds    //控件入索引时的bounds
+}
+
+// NewSpatialIndex 构造函数 bounds 为舞台范围
+func NewSpatialIndex(bounds Bounds) (index *SpatialIndex) {
+	index = &SpatialIndex{}
+	index.root = newQuadNode(bounds, 0)
+	index.nodes = make(map[*Box]*quadNode)
+	index.bounds = make(map[*Box]Bounds)
+	return index
+}
+
+func newQuadNode(bounds Bounds, deep int) *quadNode {
+	return &quadNode{bounds, deep, make([]*Box, 0), nil}
+}
+
+// Len 索引中的控件数量
+func (t *SpatialIndex) Len() int {
+	return len(t.nodes)
+}
+
+// Insert 添加控件 已经在索引中的控件会按当前bounds更新
+func (t *SpatialIndex) Insert(box *Box) {
+	if _, ok := t.nodes[box]; ok {
+		t.Remove(box)
+	}
+	bounds := box.GetBounds()
+	t.bounds[box] = bounds
+	if !boundsContain(t.root.bounds, bounds) {
+		t.grow(bounds)
+	}
+	t.place(box, bounds)
+}
+
+// 把控件放入能完整容纳它的最深的节点 节点超过容量时分裂
+func (t *SpatialIndex) place(box *Box, bounds Bounds) {
+	node := t.root
+	for node.children != nil {
+		child := node.childContains(bounds)
+		if child == nil {
+			break
+		}
+		node = child
+	}
+	node.items = append(node.items, box)
+	t.nodes[box] = node
+	if node.children == nil && len(node.items) > QUADTREECAPACITY && node.deep < QUADTREEMAXDEEP {
+		t.split(node)
+	}
+}
+
+// Update 控件的位置或尺寸变化后更新索引
+func (t *SpatialIndex) Update(box *Box) {
+	t.Insert(box)
+}
+
+// Remove 删除控件
+func (t *SpatialIndex) Remove(box *Box) {
+	node, ok := t.nodes[box]
+	if !ok {
+		return
+	}
+	node.items = removeBoxFromList(node.items, box)
+	delete(t.nodes, box)
+	delete(t.bounds, box)
+}
+
+// QueryPoint 查找bounds包含该点的控件 结果无序 需要再做精确的碰撞检测
+func (t *SpatialIndex) QueryPoint(x, y int) []*Box {
+	return t.QueryRect(Bounds{x, y, 1, 1})
+}
+
+// QueryRect 查找bounds与矩形相交的控件 结果无序
+func (t *SpatialIndex) QueryRect(rect Bounds) []*Box {
+	result := make([]*Box, 0)
+	return t.query(t.root, rect, result)
+}
+
+func (t *SpatialIndex) query(node *quadNode, rect Bounds, result []*Box) []*Box {
+	for _, box := range node.items {
+		if boundsIntersect(t.bounds[box], rect) {
+			result = append(result, box)
+		}
+	}
+	for _, child := range node.children {
+		if boundsIntersect(child.bounds, rect) {
+			result = t.query(child, rect, result)
+		}
+	}
+	return result
+}
+
+// 扩大根节点直到能完整容纳bounds 向bounds所在的方向扩大
+// 新根节点的深度比原来小 原来的节点深度不变
+func (t *SpatialIndex) grow(bounds Bounds) {
+	for !boundsContain(t.root.bounds, bounds) {
+		old := t.root
+		b := old.bounds
+		if b.width <= 0 || b.height <= 0 {
+			b.width, b.height = intMax(b.width, 1), intMax(b.height, 1)
+			old.bounds = b
+		}
+		x, y := b.x, b.y
+		if bounds.x < b.x {
+			x -= b.width
+		}
+		if bounds.y < b.y {
+			y -= b.height
+		}
+		root := newQuadNode(Bounds{x, y, b.width * 2, b.height * 2}, old.deep-1)
+		t.split(root)
+		for idx, child := range root.children {
+			if child.bounds == b {
+				root.children[idx] = old
+			}
+		}
+		t.root = root
+	}
+}
+
+// 分裂节点 能完整放入子节点的控件下沉
+func (t *SpatialIndex) split(node *quadNode) {
+	b := node.bounds
+	hw := b.width / 2
+	hh := b.height / 2
+	if hw <= 0 || hh <= 0 {
+		return
+	}
+	node.children = []*quadNode{
+		newQuadNode(Bounds{b.x, b.y, hw, hh}, node.deep+1),
+		newQuadNode(Bounds{b.x + hw, b.y, b.width - hw, hh}, node.deep+1),
+		newQuadNode(Bounds{b.x, b.y + hh, hw, b.height - hh}, node.deep+1),
+		newQuadNode(Bounds{b.x + hw, b.y + hh, b.width - hw, b.height - hh}, node.deep+1),
+	}
+	items := node.items
+	node.items = make([]*Box, 0)
+	for _, box := range items {
+		child := node.childContains(t.bounds[box])
+		if child == nil {
+			child = node
+		}
+		child.items = append(child.items, box)
+		t.nodes[box] = child
+	}
+}
+
+// 返回能完整容纳bounds的子节点
+func (t *quadNode) childContains(bounds Bounds) *quadNode {
+	for _, child := range t.children {
+		if boundsContain(child.bounds, bounds) {
+			return child
+		}
+	}
+	return nil
+}
+
+// bounds是否相交
+func boundsIntersect(b1, b2 Bounds) bool {
+	return b1.x < b2.x+b2.width && b2.x < b1.x+b1.width && b1.y < b2.y+b2.height && b2.y < b1.y+b1.height
+}
+
+// outer是否完整包含inner
+func boundsContain(outer, inner Bounds) bool {
+	return inner.x >= outer.x && inner.y >= outer.y && inner.x+inner.width <= outer.x+outer.width && inner.y+inner.height <= outer.y+outer.height
+}
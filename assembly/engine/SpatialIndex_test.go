@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// 基准测试的控件数量
+var benchmarkSizes = []int{1000, 10000, 100000}
+
+// 在舞台中随机生成控件 舞台面积随数量增长 控件密度不变
+func randomBoxes(n int) ([]*Box, Bounds) {
+	r := rand.New(rand.NewSource(1))
+	side := int(100 * math.Sqrt(float64(n)))
+	boxes := make([]*Box, n)
+	for idx := range boxes {
+		box := NewBox(r.Intn(side), r.Intn(side), 10+r.Intn(90), 10+r.Intn(90), "")
+		if idx%4 == 0 {
+			box.angle = r.Float64() * 6.28
+		}
+		boxes[idx] = box
+	}
+	return boxes, Bounds{0, 0, side, side}
+}
+
+func newBenchmarkIndex(n int) (*SpatialIndex, Bounds) {
+	boxes, stage := randomBoxes(n)
+	index := NewSpatialIndex(stage)
+	for _, box := range boxes {
+		index.Insert(box)
+	}
+	return index, stage
+}
+
+func BenchmarkInsert(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			boxes, stage := randomBoxes(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				index := NewSpatialIndex(stage)
+				for _, box := range boxes {
+					index.Insert(box)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkQueryPoint(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			index, stage := newBenchmarkIndex(n)
+			r := rand.New(rand.NewSource(2))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				index.QueryPoint(r.Intn(stage.width), r.Intn(stage.height))
+			}
+		})
+	}
+}
+
+func BenchmarkQueryRect(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			index, stage := newBenchmarkIndex(n)
+			r := rand.New(rand.NewSource(3))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				index.QueryRect(Bounds{r.Intn(stage.width), r.Intn(stage.height), 800, 600})
+			}
+		})
+	}
+}
+
+// 舞台外的控件使根节点扩大 不堆积在根节点中
+func TestSpatialIndexGrow(t *testing.T) {
+	index := NewSpatialIndex(Bounds{0, 0, 800, 600})
+	outside := []*Box{
+		NewBox(-500, -400, 20, 20, ""),
+		NewBox(3000, 100, 20, 20, ""),
+		NewBox(100, 5000, 20, 20, ""),
+	}
+	for idx := 0; idx < QUADTREECAPACITY*2; idx++ {
+		outside = append(outside, NewBox(-2000+idx*30, 9000, 20, 20, ""))
+	}
+	for _, box := range outside {
+		index.Insert(box)
+	}
+	if !boundsContain(index.root.bounds, Bounds{-2000, -400, 5020, 9420}) {
+		t.Fatalf("root %v does not contain all boxes", index.root.bounds)
+	}
+	if len(index.root.items) != 0 {
+		t.Errorf("root keeps %d items", len(index.root.items))
+	}
+	for _, box := range outside {
+		found := false
+		for _, b := range index.QueryPoint(box.x+10, box.y+10) {
+			found = found || b == box
+		}
+		if !found {
+			t.Errorf("box at %d,%d not found", box.x, box.y)
+		}
+	}
+}
@@ -1,51 +1,274 @@
 package main
 
 import (
+	"fmt"
 	"image/color"
+	"math"
 	"math/rand"
+	"sort"
 )
 
-// Style 样式
+// Style 样式 也是控件的计算样式
 type Style struct {
 	backgroundColor color.Color
 	bgTransparent   bool
 	borderColor     color.Color
 	borderWeight    int
+	opacity         float64   //整体透明度 0到1 1为不透明
+	dash            []float64 //边框虚线 实线和间隔的长度交替 为空时是实线
+	radius          int       //圆角半径
 }
 
-// StyleSheetManager 样式管理器
+// NewStyle 构造函数 不透明 实线边框 没有圆角
+func NewStyle(backgroundColor color.Color, bgTransparent bool, borderColor color.Color, borderWeight int) *Style {
+	return &Style{backgroundColor, bgTransparent, borderColor, borderWeight, 1, nil, 0}
+}
+
+// DEFAULTTHEME 默认主题
+const DEFAULTTHEME = "light"
+
+// DEFAULTSTYLESHEET 默认的主题和控件样式 交互层的样式使用 highlight selection selection-fill handle-fill snap 几个颜色
+// stage 为舞台的背景色
+const DEFAULTSTYLESHEET = `
+@theme light {
+	stage: #ffffff;
+	box-fill: #91d5ff; box-fill-hover: #bae7ff; box-border: #1890ff;
+	zone-fill: rgba(82, 196, 26, 0.2); zone-border: #52c41a;
+	wall: #595959;
+	highlight: #0000ff; selection: #1890ff; selection-fill: rgba(24, 144, 255, 0.16); handle-fill: #ffffff;
+	snap: #ff4d4f;
+}
+@theme dark {
+	stage: #141414;
+	box-fill: #112a45; box-fill-hover: #15395b; box-border: #3c9ae8;
+	zone-fill: rgba(73, 170, 25, 0.25); zone-border: #49aa19;
+	wall: #d9d9d9;
+	highlight: #faad14; selection: #40a9ff; selection-fill: rgba(64, 169, 255, 0.2); handle-fill: #141414;
+	snap: #ff7875;
+}
+@theme high-contrast {
+	stage: #000000;
+	box-fill: #000000; box-fill-hover: #333333; box-border: #ffffff;
+	zone-fill: #000000; zone-border: #00ff00;
+	wall: #ffffff;
+	highlight: #ffff00; selection: #00ffff; selection-fill: rgba(0, 255, 255, 0.25); handle-fill: #000000;
+	snap: #ff00ff;
+}
+.box { background: var(--box-fill); border: 1px solid var(--box-border); }
+.box:hover { background: var(--box-fill-hover); }
+.zone { background: var(--zone-fill); border: 2px dashed var(--zone-border); border-radius: 8px; }
+.wall { background: var(--wall); }
+.group { background: transparent; border: none; }
+`
+
+// StyleSheetManager 样式管理器 按选择器保存规则 带伪类的规则保存为 class:pseudo
+// 控件的样式按层叠计算：继承父控件的样式 依次覆盖各个类的规则 伪类规则 内联样式
+// 样式中引用的主题颜色在计算样式时替换为当前主题的颜色
 type StyleSheetManager struct {
-	styleSheet map[string]*Style
+	rules  map[string]*StyleRule
+	themes map[string]*Theme
+	theme  *Theme          //当前主题
+	cache  map[*Box]*Style //控件的计算样式
 }
 
 // NewStyleSheetManager 构造函数
 func NewStyleSheetManager() (styleSheet *StyleSheetManager) {
-	styleSheet = &StyleSheetManager{make(map[string]*Style)}
-	hoverborder := &Style{color.RGBA{0, 0, 255, 255}, true, color.RGBA{0, 0, 255, 255}, 1}
+	styleSheet = &StyleSheetManager{make(map[string]*StyleRule), make(map[string]*Theme), nil, make(map[*Box]*Style)}
+	hoverborder := NewStyle(nil, true, ThemeColor("highlight"), 1)
 	styleSheet.AddStyle("hoverborder", hoverborder)
+	selectedborder := NewStyle(nil, true, ThemeColor("selection"), 2)
+	styleSheet.AddStyle("selectedborder", selectedborder)
+	resizehandle := NewStyle(ThemeColor("handle-fill"), false, ThemeColor("selection"), 1)
+	styleSheet.AddStyle("resizehandle", resizehandle)
+	rotatehandle := NewStyle(ThemeColor("handle-fill"), false, ThemeColor("selection"), 2)
+	styleSheet.AddStyle("rotatehandle", rotatehandle)
+	droptarget := NewStyle(ThemeColor("selection-fill"), false, ThemeColor("highlight"), 2)
+	styleSheet.AddStyle("droptarget", droptarget)
+	groupscope := NewStyle(nil, true, ThemeColor("selection"), 1)
+	groupscope.dash = []float64{6, 3}
+	styleSheet.AddStyle("groupscope", groupscope)
+	snapline := NewStyle(ThemeColor("snap"), false, nil, 0)
+	styleSheet.AddStyle("snapline", snapline)
+	guide := NewStyle(ThemeColor("selection"), false, nil, 0)
+	styleSheet.AddStyle("guide", guide)
+	marquee := NewStyle(ThemeColor("selection-fill"), false, ThemeColor("selection"), 1)
+	marquee.dash = []float64{4, 2}
+	styleSheet.AddStyle("marquee", marquee)
+	if err := styleSheet.LoadStyleSheet(DEFAULTSTYLESHEET); err != nil {
+		panic(err)
+	}
+	styleSheet.SetTheme(DEFAULTTHEME)
 	return styleSheet
 }
 
-// AddStyle 添加样式
+// LoadStyleSheet 加载样式表文本 已有的同名规则和主题只覆盖声明的属性和颜色 解析出错时不修改任何规则
+func (t *StyleSheetManager) LoadStyleSheet(text string) error {
+	sheet, err := ParseStyleSheet(text)
+	if err != nil {
+		return err
+	}
+	for _, rule := range sheet.rules {
+		if old, ok := t.rules[rule.Selector()]; ok {
+			old.Merge(rule)
+		} else {
+			t.rules[rule.Selector()] = rule
+		}
+	}
+	for _, theme := range sheet.themes {
+		t.AddTheme(theme)
+	}
+	t.InvalidateAll()
+	return nil
+}
+
+// AddTheme 添加主题 已有的同名主题合并颜色
+func (t *StyleSheetManager) AddTheme(theme *Theme) {
+	if old, ok := t.themes[theme.Name()]; ok {
+		old.Merge(theme)
+	} else {
+		t.themes[theme.Name()] = theme
+	}
+	t.InvalidateAll()
+}
+
+// SetTheme 切换当前主题 需要重绘
+func (t *StyleSheetManager) SetTheme(name string) error {
+	theme, ok := t.themes[name]
+	if !ok {
+		return fmt.Errorf("stylesheet: unknown theme %q", name)
+	}
+	t.theme = theme
+	t.InvalidateAll()
+	return nil
+}
+
+// GetTheme 获取当前主题名
+func (t *StyleSheetManager) GetTheme() string {
+	if t.theme == nil {
+		return ""
+	}
+	return t.theme.Name()
+}
+
+// GetThemeNames 获取所有主题名 按名称排序
+func (t *StyleSheetManager) GetThemeNames() []string {
+	names := make([]string, 0, len(t.themes))
+	for name := range t.themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetThemeColor 获取当前主题中的颜色 没有时返回nil
+func (t *StyleSheetManager) GetThemeColor(token string) color.Color {
+	if t.theme == nil {
+		return nil
+	}
+	c, _ := t.theme.GetToken(token)
+	return c
+}
+
+// 把样式中引用的主题颜色替换为当前主题的颜色
+func (t *StyleSheetManager) resolveColors(style *Style) {
+	if token, ok := style.backgroundColor.(ThemeColor); ok {
+		style.backgroundColor = t.GetThemeColor(string(token))
+	}
+	if token, ok := style.borderColor.(ThemeColor); ok {
+		style.borderColor = t.GetThemeColor(string(token))
+	}
+}
+
+// ComputeStyle 获取控件的计算样式 结果会缓存 控件的类 父节点 状态变化后需要调用InvalidateStyle
+// 没有声明的属性继承父控件 多个类按照styleClass中的顺序覆盖 然后是 :hover :selected 最后是内联样式
+func (t *StyleSheetManager) ComputeStyle(box *Box) *Style {
+	if style, ok := t.cache[box]; ok {
+		return style
+	}
+	style := NewStyle(nil, false, nil, 0)
+	if box.parent != nil {
+		*style = *t.ComputeStyle(box.parent)
+	}
+	classes := box.GetClasses()
+	for _, class := range classes {
+		if rule, ok := t.rules[class]; ok {
+			rule.Apply(style)
+		}
+	}
+	for _, pseudo := range []string{"hover", "selected"} {
+		if (pseudo == "hover" && !box.isHovering) || (pseudo == "selected" && !box.isSelected) {
+			continue
+		}
+		for _, class := range classes {
+			if rule, ok := t.rules[class+":"+pseudo]; ok {
+				rule.Apply(style)
+			}
+		}
+	}
+	if box.inlineStyle != nil {
+		box.inlineStyle.Apply(style)
+	}
+	t.resolveColors(style)
+	t.cache[box] = style
+	return style
+}
+
+// InvalidateStyle 控件的类 内联样式 父节点或状态变化后清除它和子控件的计算样式
+func (t *StyleSheetManager) InvalidateStyle(box *Box) {
+	delete(t.cache, box)
+	for _, b := range getDescendants(box) {
+		delete(t.cache, b)
+	}
+}
+
+// InvalidateAll 规则变化后清除所有计算样式
+func (t *StyleSheetManager) InvalidateAll() {
+	t.cache = make(map[*Box]*Style)
+}
+
+// AddStyle 添加样式 样式的所有属性都作为声明的属性 覆盖同名的规则
 func (t *StyleSheetManager) AddStyle(key string, style *Style) {
-	t.styleSheet[key] = style
+	t.AddRule(key, NewStyleRule(key, style, ALLPROPERTIES))
+}
+
+// AddRule 添加规则 覆盖同名的规则
+func (t *StyleSheetManager) AddRule(selector string, rule *StyleRule) {
+	t.rules[selector] = rule
+	t.InvalidateAll()
 }
 
-// RemoveStyle 删除样式
+// RemoveStyle 删除规则
 func (t *StyleSheetManager) RemoveStyle(key string) {
-	delete(t.styleSheet, key)
+	delete(t.rules, key)
+	t.InvalidateAll()
 }
 
-// GetStyle 获取样式
+// GetStyle 获取一条规则的样式 没有声明的属性为默认值 规则不存在时返回默认样式（不绘制）
 func (t *StyleSheetManager) GetStyle(key string) *Style {
-	_, ok := t.styleSheet[key]
-	if key == "" || !ok {
-		return t.GetRandStyle()
+	style := NewStyle(nil, false, nil, 0)
+	if rule, ok := t.rules[key]; ok {
+		rule.Apply(style)
+	}
+	t.resolveColors(style)
+	return style
+}
+
+// GetRules 获取所有规则 key为选择器
+func (t *StyleSheetManager) GetRules() map[string]*StyleRule {
+	return t.rules
+}
+
+// 按透明度调整颜色的alpha
+func applyOpacity(c color.Color, opacity float64) color.Color {
+	if opacity >= 1 {
+		return c
 	}
-	return t.styleSheet[key]
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(math.Floor(float64(n.A)*math.Max(opacity, 0) + 0.5))
+	return n
 }
 
 // GetRandStyle 随机样式 用于测试
 func (t *StyleSheetManager) GetRandStyle() *Style {
-	return &Style{color.RGBA{uint8(rand.Intn(255)), uint8(rand.Intn(255)), uint8(rand.Intn(255)), 255}, false, nil, 0}
+	return NewStyle(color.RGBA{uint8(rand.Intn(255)), uint8(rand.Intn(255)), uint8(rand.Intn(255)), 255}, false, nil, 0)
 }
@@ -0,0 +1,668 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// StyleProperty 样式属性 用于记录规则中声明了哪些属性
+type StyleProperty int
+
+const (
+	// BACKGROUNDCOLOR 背景色
+	BACKGROUNDCOLOR StyleProperty = 1 << iota
+	// BGTRANSPARENT 背景透明
+	BGTRANSPARENT
+	// BORDERCOLOR 边框颜色
+	BORDERCOLOR
+	// BORDERWEIGHT 边框宽度
+	BORDERWEIGHT
+	// OPACITY 透明度
+	OPACITY
+	// DASH 边框虚线
+	DASH
+	// RADIUS 圆角
+	RADIUS
+	// ALLPROPERTIES 所有属性
+	ALLPROPERTIES = BACKGROUNDCOLOR | BGTRANSPARENT | BORDERCOLOR | BORDERWEIGHT | OPACITY | DASH | RADIUS
+)
+
+// 所有属性 按固定顺序
+var styleProperties = []StyleProperty{BACKGROUNDCOLOR, BGTRANSPARENT, BORDERCOLOR, BORDERWEIGHT, OPACITY, DASH, RADIUS}
+
+// 属性名 与文档中的字段名一致
+var stylePropertyNames = map[StyleProperty]string{
+	BACKGROUNDCOLOR: "backgroundColor",
+	BGTRANSPARENT:   "bgTransparent",
+	BORDERCOLOR:     "borderColor",
+	BORDERWEIGHT:    "borderWeight",
+	OPACITY:         "opacity",
+	DASH:            "dash",
+	RADIUS:          "radius",
+}
+
+// StyleRule 样式表中的一条规则 比如 .zone:hover { border: 2px solid #1890ff; }
+// style 中只有 properties 声明过的属性有效
+type StyleRule struct {
+	class      string
+	pseudo     string //伪类 hover selected 为空表示没有伪类
+	style      Style
+	properties StyleProperty
+	line       int
+}
+
+// NewStyleRule 构造函数 selector 为 class 或 class:pseudo properties 为声明的属性
+func NewStyleRule(selector string, style *Style, properties StyleProperty) *StyleRule {
+	rule := &StyleRule{}
+	rule.class = selector
+	if idx := strings.Index(selector, ":"); idx >= 0 {
+		rule.class = selector[:idx]
+		rule.pseudo = selector[idx+1:]
+	}
+	rule.style = *style
+	rule.properties = properties
+	return rule
+}
+
+// Selector 规则的选择器名 也是规则在样式管理器中的key 比如 zone zone:hover
+func (t *StyleRule) Selector() string {
+	if t.pseudo == "" {
+		return t.class
+	}
+	return t.class + ":" + t.pseudo
+}
+
+// Apply 把规则声明的属性覆盖到样式上
+func (t *StyleRule) Apply(style *Style) {
+	if t.properties&BACKGROUNDCOLOR != 0 {
+		style.backgroundColor = t.style.backgroundColor
+	}
+	if t.properties&BGTRANSPARENT != 0 {
+		style.bgTransparent = t.style.bgTransparent
+	}
+	if t.properties&BORDERCOLOR != 0 {
+		style.borderColor = t.style.borderColor
+	}
+	if t.properties&BORDERWEIGHT != 0 {
+		style.borderWeight = t.style.borderWeight
+	}
+	if t.properties&OPACITY != 0 {
+		style.opacity = t.style.opacity
+	}
+	if t.properties&DASH != 0 {
+		style.dash = t.style.dash
+	}
+	if t.properties&RADIUS != 0 {
+		style.radius = t.style.radius
+	}
+}
+
+// Merge 把另一条规则声明的属性合并进来 后声明的覆盖先声明的
+func (t *StyleRule) Merge(other *StyleRule) {
+	other.Apply(&t.style)
+	t.properties |= other.properties
+}
+
+// StyleSheetError 样式表解析错误 行列从1开始
+type StyleSheetError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (t *StyleSheetError) Error() string {
+	return fmt.Sprintf("stylesheet:%d:%d: %s", t.Line, t.Column, t.Msg)
+}
+
+var errUnknownProperty = errors.New("unknown property")
+
+// 支持的伪类
+var stylePseudoClasses = map[string]bool{"hover": true, "selected": true}
+
+// 命名颜色
+var namedColors = map[string]color.Color{
+	"black":   color.NRGBA{0, 0, 0, 255},
+	"white":   color.NRGBA{255, 255, 255, 255},
+	"red":     color.NRGBA{255, 0, 0, 255},
+	"green":   color.NRGBA{0, 128, 0, 255},
+	"blue":    color.NRGBA{0, 0, 255, 255},
+	"yellow":  color.NRGBA{255, 255, 0, 255},
+	"orange":  color.NRGBA{255, 165, 0, 255},
+	"purple":  color.NRGBA{128, 0, 128, 255},
+	"gray":    color.NRGBA{128, 128, 128, 255},
+	"grey":    color.NRGBA{128, 128, 128, 255},
+	"silver":  color.NRGBA{192, 192, 192, 255},
+	"navy":    color.NRGBA{0, 0, 128, 255},
+	"teal":    color.NRGBA{0, 128, 128, 255},
+	"maroon":  color.NRGBA{128, 0, 0, 255},
+	"olive":   color.NRGBA{128, 128, 0, 255},
+	"lime":    color.NRGBA{0, 255, 0, 255},
+	"aqua":    color.NRGBA{0, 255, 255, 255},
+	"cyan":    color.NRGBA{0, 255, 255, 255},
+	"fuchsia": color.NRGBA{255, 0, 255, 255},
+	"magenta": color.NRGBA{255, 0, 255, 255},
+}
+
+// StyleSheet 解析出的样式表 规则和主题按出现顺序排列
+type StyleSheet struct {
+	rules  []*StyleRule
+	themes []*Theme
+}
+
+// ParseStyleSheet 解析样式表文本
+//
+//	/* 注释 */
+//	@theme light { zone-fill: #e6f7ff; wall: #595959; }
+//	.zone, .room { background: var(--zone-fill); border: 1px solid rgb(24, 144, 255); border-radius: 4px; }
+//	.zone:hover { border: 2px dashed navy; opacity: 0.8; }
+func ParseStyleSheet(text string) (*StyleSheet, error) {
+	p := &styleParser{[]rune(text), 0, 1, 1}
+	sheet := &StyleSheet{make([]*StyleRule, 0), make([]*Theme, 0)}
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return sheet, nil
+		}
+		if p.peek() == '@' {
+			theme, err := p.parseTheme()
+			if err != nil {
+				return nil, err
+			}
+			sheet.themes = append(sheet.themes, theme)
+			continue
+		}
+		list, err := p.parseRule()
+		if err != nil {
+			return nil, err
+		}
+		sheet.rules = append(sheet.rules, list...)
+	}
+}
+
+// ParseStyleDeclarations 解析内联样式 比如 background: red; opacity: 0.5
+func ParseStyleDeclarations(text string) (*StyleRule, error) {
+	p := &styleParser{[]rune(text), 0, 1, 1}
+	rule := &StyleRule{}
+	rule.style = *NewStyle(nil, false, nil, 0)
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return rule, nil
+		}
+		if p.peek() == '}' {
+			return nil, p.errorf(p.line, p.column, "unexpected '}'")
+		}
+		if err := p.parseDeclaration(rule); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// styleParser 样式表解析器 记录当前位置的行列
+type styleParser struct {
+	text   []rune
+	pos    int
+	line   int
+	column int
+}
+
+func (t *styleParser) eof() bool {
+	return t.pos >= len(t.text)
+}
+
+func (t *styleParser) peek() rune {
+	if t.eof() {
+		return 0
+	}
+	return t.text[t.pos]
+}
+
+func (t *styleParser) next() rune {
+	r := t.text[t.pos]
+	t.pos++
+	if r == '\n' {
+		t.line++
+		t.column = 1
+	} else {
+		t.column++
+	}
+	return r
+}
+
+func (t *styleParser) errorf(line, column int, format string, args ...interface{}) error {
+	return &StyleSheetError{line, column, fmt.Sprintf(format, args...)}
+}
+
+// 跳过空白和注释
+func (t *styleParser) skipSpace() error {
+	for !t.eof() {
+		r := t.peek()
+		if unicode.IsSpace(r) {
+			t.next()
+			continue
+		}
+		if r == '/' && t.pos+1 < len(t.text) && t.text[t.pos+1] == '*' {
+			line, column := t.line, t.column
+			t.next()
+			t.next()
+			for {
+				if t.eof() {
+					return t.errorf(line, column, "unterminated comment")
+				}
+				if t.next() == '*' && t.peek() == '/' {
+					t.next()
+					break
+				}
+			}
+			continue
+		}
+		break
+	}
+	return nil
+}
+
+func (t *styleParser) expect(r rune) error {
+	if err := t.skipSpace(); err != nil {
+		return err
+	}
+	if t.eof() {
+		return t.errorf(t.line, t.column, "expected %q, found end of input", r)
+	}
+	if t.peek() != r {
+		return t.errorf(t.line, t.column, "expected %q, found %q", r, t.peek())
+	}
+	t.next()
+	return nil
+}
+
+// 标识符 字母 数字 - _
+func (t *styleParser) readIdent() string {
+	start := t.pos
+	for !t.eof() {
+		r := t.peek()
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			break
+		}
+		t.next()
+	}
+	return string(t.text[start:t.pos])
+}
+
+// 一条规则 可以有多个用逗号分隔的选择器
+func (t *styleParser) parseRule() ([]*StyleRule, error) {
+	rules := make([]*StyleRule, 0, 1)
+	for {
+		rule, err := t.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+		if err := t.skipSpace(); err != nil {
+			return nil, err
+		}
+		if t.peek() != ',' {
+			break
+		}
+		t.next()
+		if err := t.skipSpace(); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.expect('{'); err != nil {
+		return nil, err
+	}
+	decl := &StyleRule{}
+	decl.style = *NewStyle(nil, false, nil, 0)
+	for {
+		if err := t.skipSpace(); err != nil {
+			return nil, err
+		}
+		if t.eof() {
+			return nil, t.errorf(t.line, t.column, "expected '}', found end of input")
+		}
+		if t.peek() == '}' {
+			t.next()
+			break
+		}
+		if err := t.parseDeclaration(decl); err != nil {
+			return nil, err
+		}
+	}
+	for _, rule := range rules {
+		rule.style = decl.style
+		rule.properties = decl.properties
+	}
+	return rules, nil
+}
+
+// @theme name { token: color; } 颜色名可以带--前缀
+func (t *styleParser) parseTheme() (*Theme, error) {
+	line, column := t.line, t.column
+	t.next()
+	if keyword := t.readIdent(); keyword != "theme" {
+		return nil, t.errorf(line, column, "unknown at-rule @%s", keyword)
+	}
+	if err := t.skipSpace(); err != nil {
+		return nil, err
+	}
+	name := t.readIdent()
+	if name == "" {
+		return nil, t.errorf(t.line, t.column, "expected theme name")
+	}
+	if err := t.expect('{'); err != nil {
+		return nil, err
+	}
+	theme := NewTheme(name)
+	for {
+		if err := t.skipSpace(); err != nil {
+			return nil, err
+		}
+		if t.eof() {
+			return nil, t.errorf(t.line, t.column, "expected '}', found end of input")
+		}
+		if t.peek() == '}' {
+			t.next()
+			return theme, nil
+		}
+		tl, tc := t.line, t.column
+		token := strings.TrimPrefix(t.readIdent(), "--")
+		if token == "" {
+			return nil, t.errorf(tl, tc, "expected color name, found %q", t.peek())
+		}
+		if err := t.expect(':'); err != nil {
+			return nil, err
+		}
+		if err := t.skipSpace(); err != nil {
+			return nil, err
+		}
+		vl, vc := t.line, t.column
+		value := t.readValue()
+		c, err := parseColor(value)
+		if err == nil {
+			if _, ok := c.(ThemeColor); ok {
+				err = fmt.Errorf("theme color can not reference %q", value)
+			}
+		}
+		if err != nil {
+			return nil, t.errorf(vl, vc, "%s: %v", token, err)
+		}
+		theme.SetToken(token, c)
+	}
+}
+
+// 读取到分号或右括号为止的值 并跳过分号
+func (t *styleParser) readValue() string {
+	start := t.pos
+	for !t.eof() && t.peek() != ';' && t.peek() != '}' {
+		t.next()
+	}
+	value := strings.TrimSpace(string(t.text[start:t.pos]))
+	if t.peek() == ';' {
+		t.next()
+	}
+	return value
+}
+
+// .class 或 .class:pseudo
+func (t *styleParser) parseSelector() (*StyleRule, error) {
+	line, column := t.line, t.column
+	if t.peek() != '.' {
+		return nil, t.errorf(line, column, "expected class selector")
+	}
+	t.next()
+	rule := &StyleRule{}
+	rule.line = line
+	rule.class = t.readIdent()
+	if rule.class == "" {
+		return nil, t.errorf(t.line, t.column, "expected class name")
+	}
+	if t.peek() == ':' {
+		t.next()
+		pl, pc := t.line, t.column
+		rule.pseudo = t.readIdent()
+		if !stylePseudoClasses[rule.pseudo] {
+			return nil, t.errorf(pl, pc, "unknown pseudo-class %q", rule.pseudo)
+		}
+	}
+	return rule, nil
+}
+
+// 属性: 值; 最后一条声明的分号可以省略
+func (t *styleParser) parseDeclaration(rule *StyleRule) error {
+	line, column := t.line, t.column
+	name := strings.ToLower(t.readIdent())
+	if name == "" {
+		return t.errorf(line, column, "expected property name, found %q", t.peek())
+	}
+	if err := t.expect(':'); err != nil {
+		return err
+	}
+	if err := t.skipSpace(); err != nil {
+		return err
+	}
+	vl, vc := t.line, t.column
+	value := t.readValue()
+	if value == "" {
+		return t.errorf(vl, vc, "missing value for %q", name)
+	}
+	if err := applyDeclaration(rule, name, value); err == errUnknownProperty {
+		return t.errorf(line, column, "unknown property %q", name)
+	} else if err != nil {
+		return t.errorf(vl, vc, "%s: %v", name, err)
+	}
+	return nil
+}
+
+// 把一条声明写入规则
+func applyDeclaration(rule *StyleRule, name, value string) error {
+	style := &rule.style
+	switch name {
+	case "background", "background-color":
+		if value == "transparent" || value == "none" {
+			style.bgTransparent = true
+			rule.properties |= BGTRANSPARENT
+			return nil
+		}
+		c, err := parseColor(value)
+		if err != nil {
+			return err
+		}
+		style.backgroundColor = c
+		style.bgTransparent = false
+		rule.properties |= BACKGROUNDCOLOR | BGTRANSPARENT
+	case "border":
+		if value == "none" {
+			style.borderWeight = 0
+			rule.properties |= BORDERWEIGHT
+			return nil
+		}
+		for _, part := range splitValue(value) {
+			if weight, err := parseLength(part); err == nil {
+				style.borderWeight = weight
+				rule.properties |= BORDERWEIGHT
+			} else if dash, ok := borderStyleDash(part); ok {
+				style.dash = dash
+				rule.properties |= DASH
+			} else if c, err := parseColor(part); err == nil {
+				style.borderColor = c
+				rule.properties |= BORDERCOLOR
+			} else {
+				return fmt.Errorf("invalid value %q", part)
+			}
+		}
+	case "border-color":
+		c, err := parseColor(value)
+		if err != nil {
+			return err
+		}
+		style.borderColor = c
+		rule.properties |= BORDERCOLOR
+	case "border-width":
+		weight, err := parseLength(value)
+		if err != nil {
+			return err
+		}
+		style.borderWeight = weight
+		rule.properties |= BORDERWEIGHT
+	case "border-style":
+		dash, ok := borderStyleDash(value)
+		if !ok {
+			return fmt.Errorf("invalid border style %q", value)
+		}
+		style.dash = dash
+		rule.properties |= DASH
+	case "border-dash":
+		dash := make([]float64, 0)
+		for _, part := range splitValue(value) {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(part, "px"), 64)
+			if err != nil || v < 0 {
+				return fmt.Errorf("invalid dash %q", part)
+			}
+			dash = append(dash, v)
+		}
+		style.dash = dash
+		rule.properties |= DASH
+	case "border-radius":
+		radius, err := parseLength(value)
+		if err != nil {
+			return err
+		}
+		style.radius = radius
+		rule.properties |= RADIUS
+	case "opacity":
+		opacity, err := parseNumberOrPercent(value)
+		if err != nil || opacity < 0 || opacity > 1 {
+			return fmt.Errorf("invalid opacity %q", value)
+		}
+		style.opacity = opacity
+		rule.properties |= OPACITY
+	default:
+		return errUnknownProperty
+	}
+	return nil
+}
+
+// 按空白分隔 括号内的空白不分隔 比如 2px solid rgb(0, 0, 0)
+func splitValue(value string) []string {
+	parts := make([]string, 0)
+	deep := 0
+	start := -1
+	for idx, r := range value {
+		switch {
+		case r == '(':
+			deep++
+		case r == ')':
+			deep--
+		case unicode.IsSpace(r) && deep == 0:
+			if start >= 0 {
+				parts = append(parts, value[start:idx])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = idx
+		}
+	}
+	if start >= 0 {
+		parts = append(parts, value[start:])
+	}
+	return parts
+}
+
+// 长度 比如 2 2px
+func parseLength(value string) (int, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(value, "px"), 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid length %q", value)
+	}
+	return round(v), nil
+}
+
+// 数字或百分比 比如 0.5 50%
+func parseNumberOrPercent(value string) (float64, error) {
+	if strings.HasSuffix(value, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		return v / 100, err
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// 边框线型对应的虚线
+func borderStyleDash(value string) ([]float64, bool) {
+	switch value {
+	case "solid":
+		return nil, true
+	case "dashed":
+		return []float64{6, 4}, true
+	case "dotted":
+		return []float64{2, 2}, true
+	}
+	return nil, false
+}
+
+// 解析颜色 #rgb #rgba #rrggbb #rrggbbaa rgb() rgba() 命名颜色 以及引用主题颜色的 var(--token)
+func parseColor(value string) (color.Color, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if strings.HasPrefix(value, "var(") && strings.HasSuffix(value, ")") {
+		token := strings.TrimPrefix(strings.TrimSpace(value[4:len(value)-1]), "--")
+		if token == "" {
+			return nil, fmt.Errorf("invalid color %q", value)
+		}
+		return ThemeColor(token), nil
+	}
+	if c, ok := namedColors[value]; ok {
+		return c, nil
+	}
+	if value == "transparent" {
+		return color.NRGBA{0, 0, 0, 0}, nil
+	}
+	if strings.HasPrefix(value, "#") {
+		hex := value[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			long := make([]byte, 0, 8)
+			for idx := 0; idx < len(hex); idx++ {
+				long = append(long, hex[idx], hex[idx])
+			}
+			hex = string(long)
+		}
+		return parseHexColor("#" + hex)
+	}
+	for _, fn := range []string{"rgba(", "rgb("} {
+		if !strings.HasPrefix(value, fn) || !strings.HasSuffix(value, ")") {
+			continue
+		}
+		args := strings.Split(value[len(fn):len(value)-1], ",")
+		if len(args) != 3 && len(args) != 4 {
+			return nil, fmt.Errorf("invalid color %q", value)
+		}
+		channels := [4]uint8{0, 0, 0, 255}
+		for idx, arg := range args {
+			arg = strings.TrimSpace(arg)
+			if idx == 3 {
+				alpha, err := parseNumberOrPercent(arg)
+				if err != nil || alpha < 0 || alpha > 1 {
+					return nil, fmt.Errorf("invalid color %q", value)
+				}
+				channels[3] = uint8(math.Floor(alpha*255 + 0.5))
+				continue
+			}
+			v, err := strconv.Atoi(arg)
+			if err != nil || v < 0 || v > 255 {
+				return nil, fmt.Errorf("invalid color %q", value)
+			}
+			channels[idx] = uint8(v)
+		}
+		return color.NRGBA{channels[0], channels[1], channels[2], channels[3]}, nil
+	}
+	return nil, fmt.Errorf("invalid color %q", value)
+}
@@ -0,0 +1,48 @@
+package main
+
+import "image/color"
+
+// ThemeColor 引用主题中的颜色 比如样式表中的 var(--zone-fill)
+// 计算样式时由样式管理器替换为当前主题的颜色 未替换时为透明
+type ThemeColor string
+
+// RGBA 实现color.Color
+func (t ThemeColor) RGBA() (r, g, b, a uint32) {
+	return 0, 0, 0, 0
+}
+
+// Theme 主题 把语义化的颜色名映射到具体的颜色
+//
+//	@theme dark { zone-fill: #1f3a1f; wall: #d9d9d9; highlight: #faad14; selection: #40a9ff; }
+type Theme struct {
+	name   string
+	tokens map[string]color.Color
+}
+
+// NewTheme 构造函数
+func NewTheme(name string) *Theme {
+	return &Theme{name, make(map[string]color.Color)}
+}
+
+// Name 主题名
+func (t *Theme) Name() string {
+	return t.name
+}
+
+// SetToken 设置颜色
+func (t *Theme) SetToken(token string, c color.Color) {
+	t.tokens[token] = c
+}
+
+// GetToken 获取颜色
+func (t *Theme) GetToken(token string) (color.Color, bool) {
+	c, ok := t.tokens[token]
+	return c, ok
+}
+
+// Merge 合并另一个主题的颜色 同名的覆盖
+func (t *Theme) Merge(other *Theme) {
+	for token, c := range other.tokens {
+		t.tokens[token] = c
+	}
+}
@@ -1,6 +1,10 @@
+//go:build js && wasm
+// +build js,wasm
+
 package main
 
 import (
+	"fmt"
 	"math"
 	"math/rand"
 	"syscall/js"
@@ -13,7 +17,8 @@ func main() {
 	done := make(chan int, 0)
 	doc := js.Global().Get("document")
 
-	engien := NewEngine()
+	engien := NewBrowserEngine()
+	defer ReleaseBrowserEngine(engien)
 	// 添加矩形
 	addRectHandler := js.NewCallback(func(args []js.Value) {
 		x := rand.Intn(500)
@@ -33,12 +38,258 @@ func main() {
 		// n++
 		// angle := float64(0)
 		angle := rand.Float64() * 2 * math.Pi
-		engien.CreateNewBox(x, y, w, h, angle, "")
+		classes := []string{"box", "zone", "wall"}
+		engien.CreateNewBox(x, y, w, h, angle, classes[rand.Intn(len(classes))])
 		// engien.CreateNewBox(0, 0, 200, 200, "")
 	})
 	defer addRectHandler.Release()
 	doc.Call("getElementById", "add-rect-btn").Call("addEventListener", "click", addRectHandler)
 
+	// 撤销 重做
+	undoHandler := js.NewCallback(func(args []js.Value) {
+		engien.Undo()
+	})
+	defer undoHandler.Release()
+	doc.Call("getElementById", "undo-btn").Call("addEventListener", "click", undoHandler)
+	redoHandler := js.NewCallback(func(args []js.Value) {
+		engien.Redo()
+	})
+	defer redoHandler.Release()
+	doc.Call("getElementById", "redo-btn").Call("addEventListener", "click", redoHandler)
+
+	// 缩放到适应窗口 适应选中
+	zoomFitHandler := js.NewCallback(func(args []js.Value) {
+		engien.ZoomToFit()
+	})
+	defer zoomFitHandler.Release()
+	doc.Call("getElementById", "zoom-fit-btn").Call("addEventListener", "click", zoomFitHandler)
+	zoomSelectionHandler := js.NewCallback(func(args []js.Value) {
+		engien.ZoomToSelection()
+	})
+	defer zoomSelectionHandler.Release()
+	doc.Call("getElementById", "zoom-selection-btn").Call("addEventListener", "click", zoomSelectionHandler)
+
+	// 层级调整
+	zorderButtons := map[string]ZOrderOperation{
+		"bring-front-btn":   BRINGTOFRONT,
+		"bring-forward-btn": BRINGFORWARD,
+		"send-backward-btn": SENDBACKWARD,
+		"send-back-btn":     SENDTOBACK,
+	}
+	for id, op := range zorderButtons {
+		op := op
+		reorderHandler := js.NewCallback(func(args []js.Value) {
+			engien.ReorderSelection(op)
+		})
+		defer reorderHandler.Release()
+		doc.Call("getElementById", id).Call("addEventListener", "click", reorderHandler)
+	}
+
+	// 对齐和分布
+	alignButtons := map[string]AlignOperation{
+		"align-left-btn":    ALIGNLEFT,
+		"align-hcenter-btn": ALIGNHCENTER,
+		"align-right-btn":   ALIGNRIGHT,
+		"align-top-btn":     ALIGNTOP,
+		"align-vcenter-btn": ALIGNVCENTER,
+		"align-bottom-btn":  ALIGNBOTTOM,
+	}
+	for id, op := range alignButtons {
+		op := op
+		alignHandler := js.NewCallback(func(args []js.Value) {
+			engien.AlignSelection(op)
+		})
+		defer alignHandler.Release()
+		doc.Call("getElementById", id).Call("addEventListener", "click", alignHandler)
+	}
+	distributeButtons := map[string]DistributeOperation{
+		"distribute-h-btn": DISTRIBUTEHGAP,
+		"distribute-v-btn": DISTRIBUTEVGAP,
+	}
+	for id, op := range distributeButtons {
+		op := op
+		distributeHandler := js.NewCallback(func(args []js.Value) {
+			engien.DistributeSelection(op)
+		})
+		defer distributeHandler.Release()
+		doc.Call("getElementById", id).Call("addEventListener", "click", distributeHandler)
+	}
+
+	// 组合 取消组合
+	groupHandler := js.NewCallback(func(args []js.Value) {
+		engien.GroupSelection()
+	})
+	defer groupHandler.Release()
+	doc.Call("getElementById", "group-btn").Call("addEventListener", "click", groupHandler)
+	ungroupHandler := js.NewCallback(func(args []js.Value) {
+		engien.UngroupSelection()
+	})
+	defer ungroupHandler.Release()
+	doc.Call("getElementById", "ungroup-btn").Call("addEventListener", "click", ungroupHandler)
+
+	// 主题切换 选项由引擎中的主题生成
+	themeSelect := doc.Call("getElementById", "theme-select")
+	for _, name := range engien.GetThemeNames() {
+		option := doc.Call("createElement", "option")
+		option.Set("value", name)
+		option.Set("textContent", name)
+		option.Set("selected", name == engien.GetTheme())
+		themeSelect.Call("appendChild", option)
+	}
+	themeChangeHandler := js.NewCallback(func(args []js.Value) {
+		if err := engien.SetTheme(themeSelect.Get("value").String()); err != nil {
+			fmt.Println("主题错误：", err)
+		}
+	})
+	defer themeChangeHandler.Release()
+	themeSelect.Call("addEventListener", "change", themeChangeHandler)
+
+	// 保存和加载 保存结果通过window.documentSaved返回给页面
+	saveHandler := js.NewCallback(func(args []js.Value) {
+		data, err := engien.Save()
+		if err != nil {
+			fmt.Println("保存失败：", err)
+			return
+		}
+		documentSaved := js.Global().Get("window").Get("documentSaved")
+		if documentSaved.Type() == js.TypeFunction {
+			documentSaved.Invoke(string(data))
+		}
+	})
+	defer saveHandler.Release()
+	js.Global().Get("window").Set("saveDocument", saveHandler)
+	loadHandler := js.NewCallback(func(args []js.Value) {
+		if err := engien.Load([]byte(args[0].String())); err != nil {
+			fmt.Println("加载失败：", err)
+		}
+	})
+	defer loadHandler.Release()
+	js.Global().Get("window").Set("loadDocument", loadHandler)
+
+	// 导出SVG window.exportSVG(selectionOnly) 结果通过window.svgExported返回给页面
+	exportSVGHandler := js.NewCallback(func(args []js.Value) {
+		selectionOnly := len(args) > 0 && args[0].Bool()
+		data, err := engien.ExportSVG(selectionOnly, true)
+		if err != nil {
+			fmt.Println("导出失败：", err)
+			return
+		}
+		svgExported := js.Global().Get("window").Get("svgExported")
+		if svgExported.Type() == js.TypeFunction {
+			svgExported.Invoke(string(data))
+		}
+	})
+	defer exportSVGHandler.Release()
+	js.Global().Get("window").Set("exportSVG", exportSVGHandler)
+
+	// 加载样式表 window.loadStyleSheet(text)
+	loadStyleSheetHandler := js.NewCallback(func(args []js.Value) {
+		if err := engien.LoadStyleSheet(args[0].String()); err != nil {
+			fmt.Println("样式表错误：", err)
+		}
+	})
+	defer loadStyleSheetHandler.Release()
+	js.Global().Get("window").Set("loadStyleSheet", loadStyleSheetHandler)
+
+	// 容器规则 window.setContainRule(containerClass, ...childClasses)
+	setContainRuleHandler := js.NewCallback(func(args []js.Value) {
+		childClasses := make([]string, 0, len(args))
+		for _, arg := range args[1:] {
+			childClasses = append(childClasses, arg.String())
+		}
+		engien.SetContainRule(args[0].String(), childClasses...)
+	})
+	defer setContainRuleHandler.Release()
+	js.Global().Get("window").Set("setContainRule", setContainRuleHandler)
+
+	// 吸附 window.setSnapGrid(size) window.setSnapToBoxes(enabled) window.addGuide(vertical, position)
+	setSnapGridHandler := js.NewCallback(func(args []js.Value) {
+		engien.SetSnapGrid(args[0].Int())
+	})
+	defer setSnapGridHandler.Release()
+	js.Global().Get("window").Set("setSnapGrid", setSnapGridHandler)
+	setSnapToBoxesHandler := js.NewCallback(func(args []js.Value) {
+		engien.SetSnapToBoxes(args[0].Bool())
+	})
+	defer setSnapToBoxesHandler.Release()
+	js.Global().Get("window").Set("setSnapToBoxes", setSnapToBoxesHandler)
+	addGuideHandler := js.NewCallback(func(args []js.Value) {
+		engien.AddGuide(args[0].Bool(), args[1].Int())
+	})
+	defer addGuideHandler.Release()
+	js.Global().Get("window").Set("addGuide", addGuideHandler)
+
+	// 按id选中和命名控件 window.selectBoxByID(id) window.setBoxName(id, name) window.findBoxesByName(name)
+	selectBoxByIDHandler := js.NewCallback(func(args []js.Value) {
+		if box := engien.GetBoxByID(args[0].String()); box != nil {
+			engien.SelectBox(box)
+		}
+	})
+	defer selectBoxByIDHandler.Release()
+	js.Global().Get("window").Set("selectBoxByID", selectBoxByIDHandler)
+	setBoxNameHandler := js.NewCallback(func(args []js.Value) {
+		engien.SetBoxName(engien.GetBoxByID(args[0].String()), args[1].String())
+	})
+	defer setBoxNameHandler.Release()
+	js.Global().Get("window").Set("setBoxName", setBoxNameHandler)
+	findBoxesByNameHandler := js.NewCallback(func(args []js.Value) {
+		ids := js.Global().Get("Array").New()
+		for _, box := range engien.FindBoxesByName(args[0].String()) {
+			ids.Call("push", box.GetID())
+		}
+		args[1].Invoke(ids)
+	})
+	defer findBoxesByNameHandler.Release()
+	js.Global().Get("window").Set("findBoxesByName", findBoxesByNameHandler)
+
+	// 切换主题 window.setTheme(name)
+	setThemeHandler := js.NewCallback(func(args []js.Value) {
+		if err := engien.SetTheme(args[0].String()); err != nil {
+			fmt.Println("主题错误：", err)
+		}
+	})
+	defer setThemeHandler.Release()
+	js.Global().Get("window").Set("setTheme", setThemeHandler)
+
+	// 历史变化通知页面
+	engien.AddHistoryHandler(func(canUndo, canRedo bool) {
+		historyChanged := js.Global().Get("window").Get("historyChanged")
+		if historyChanged.Type() == js.TypeFunction {
+			historyChanged.Invoke(canUndo, canRedo)
+		}
+	})
+
+	// 控件行为通知页面 window.boxEvent(id, event)
+	engien.AddBoxEventHandler(func(box *Box, event BoxEvent) {
+		boxEvent := js.Global().Get("window").Get("boxEvent")
+		if boxEvent.Type() == js.TypeFunction {
+			boxEvent.Invoke(box.GetID(), int(event))
+		}
+	})
+
+	// 选中变化通知页面
+	engien.AddSelectionHandler(func(selection []*Box) {
+		selectionChanged := js.Global().Get("window").Get("selectionChanged")
+		if selectionChanged.Type() != js.TypeFunction {
+			return
+		}
+		list := js.Global().Get("Array").New()
+		for _, box := range selection {
+			px, py := box.GetPosition()
+			info := js.Global().Get("Object").New()
+			info.Set("id", box.GetID())
+			info.Set("name", box.GetName())
+			info.Set("x", px)
+			info.Set("y", py)
+			info.Set("width", box.width)
+			info.Set("height", box.height)
+			info.Set("angle", box.angle)
+			info.Set("styleClass", box.styleClass)
+			list.Call("push", info)
+		}
+		selectionChanged.Invoke(list)
+	})
+
 	// 离开页面之前关闭线程
 	destroyHandler := js.NewCallback(func(args []js.Value) {
 		done <- 0
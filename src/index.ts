@@ -11,6 +11,20 @@ const ctx = canvas.getContext('2d');
 	
 	resizeCanvas();
 	window.addEventListener('resize', resizeCanvas);
+	// 滚轮用于缩放 空格用于平移 不滚动页面
+	mainBox.addEventListener('wheel', e => e.preventDefault(), { passive: false });
+	document.addEventListener('keydown', e => {
+		let tag = (e.target as HTMLElement).tagName;
+		if(e.key === ' ' && tag !== 'INPUT' && tag !== 'TEXTAREA') e.preventDefault();
+	});
+
+	document.getElementById('save-btn').addEventListener('click', () => window['saveDocument']());
+	document.getElementById('load-btn').addEventListener('click', () => {
+		let data = localStorage.getItem('xmap-document');
+		if(data) window['loadDocument'](data);
+	});
+	document.getElementById('export-svg-btn').addEventListener('click', () => window['exportSVG'](false));
+	document.getElementById('export-selection-svg-btn').addEventListener('click', () => window['exportSVG'](true));
 })();
 
 function resizeCanvas() {
@@ -20,12 +34,35 @@ function resizeCanvas() {
 	canvas.setAttribute('height', h + 'px');
 	canvas.style.width = w + 'px';
 	canvas.style.height = h + 'px';
+	if(window['resizeStage']) window['resizeStage'](w, h);
 }
 
 window['isReady'] = function(callback) {
 	callback(mainBox.clientWidth, mainBox.clientHeight)
 }
 
+window['documentSaved'] = function(data) {
+	localStorage.setItem('xmap-document', data);
+}
+
+window['svgExported'] = function(data) {
+	let url = URL.createObjectURL(new Blob([data], { type: 'image/svg+xml' }));
+	let link = document.createElement('a');
+	link.href = url;
+	link.download = 'xmap.svg';
+	link.click();
+	URL.revokeObjectURL(url);
+}
+
+window['historyChanged'] = function(canUndo, canRedo) {
+	(document.getElementById('undo-btn') as HTMLButtonElement).disabled = !canUndo;
+	(document.getElementById('redo-btn') as HTMLButtonElement).disabled = !canRedo;
+}
+
+window['selectionChanged'] = function(selection) {
+	console.log('Selection changed: ', selection);
+}
+
 var n = 0;
 
 window['printer'] = function(arr, x, y, width, height) {